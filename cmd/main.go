@@ -1,48 +1,125 @@
-// Main implements an entry point of the Lambda function.
+// Main implements an entry point of the Lambda function. Run with -http flag, it serves
+// HTTP requests locally instead, e.g. "go run ./cmd -http :8080 -memory".
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/adapter"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/handlers"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
 )
 
-// HandleRequest routes request to handler based on method and availability of "email"
-// query parameter.
-func HandleRequest(
-	request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	// Logging.
-	log.Printf("Request: %v", request)
-	log.Printf("HTTPMethod: %v", request.HTTPMethod)
-	log.Printf("Headers: %v", request.Headers)
-	log.Printf("PathParameters: %v", request.PathParameters)
-	log.Printf("QueryStringParameters: %v", request.QueryStringParameters)
-	log.Printf("Body: %v", request.Body)
-
-	// Check "email" query parameter existence.
-	_, ok := request.QueryStringParameters["email"]
-
-	switch request.HTTPMethod {
-	case "GET":
-		// If "email" query parameter provided call GetUser else GetUsers.
-		if ok {
-			return handlers.GetUser(request)
-		} else {
-			return handlers.GetUsers()
-		}
-	case "POST":
-		return handlers.CreateUser(request)
-	case "PUT":
-		return handlers.UpdateUser(request)
-	case "DELETE":
-		return handlers.DeleteUser(request)
-	default:
-		return handlers.UnhandledHTTPMethod(request)
+var (
+	httpAddress = flag.String("http", "", "serve HTTP requests on address (e.g. :8080) instead of Lambda events")
+	memory      = flag.Bool("memory", false, "store items in memory instead of DynamoDB table")
+)
+
+// router routes requests of resources to handlers. Query-parameter forms of endpoints
+// predating resource paths are deprecated but keep working.
+var router = newRouter()
+
+// newRouter returns router of all endpoints.
+func newRouter() *handlers.Router {
+	r := handlers.NewRouter()
+
+	// Identify, log and time requests, emit metrics of the cache of users, answer CORS preflight
+	// requests, encode (and compress) responses in the media type (and content coding) the
+	// client accepts, recover panics of handlers, authenticate requests, and scope them to
+	// tenants with reads consistent on demand. Recovery of handlers goes inside CORS and
+	// encoding, so responses to panics are readable by browsers and encoded like any other
+	// error, while outermost recovery keeps panics of middleware from failing invocations with
+	// 502 of API Gateway. Authentication goes after CORS, so preflight requests without
+	// credentials are answered.
+	r.Use(
+		handlers.WithRecovery,
+		handlers.WithRequestID,
+		handlers.WithRequestLogging,
+		handlers.WithTiming,
+		handlers.WithCacheMetrics,
+		handlers.WithCORS,
+		handlers.WithCompression,
+		handlers.WithContentNegotiation,
+		handlers.WithRecovery,
+		handlers.WithProblemInstance,
+		handlers.WithAuth,
+		handlers.WithTenant,
+		handlers.WithReadConsistency,
+	)
+
+	// Users.
+	r.Handle("GET", "/users", getUsers)
+	r.Handle("POST", "/users", handlers.CreateUser)
+	r.Handle("PUT", "/users", handlers.Deprecated(handlers.UpdateUser, "/users/{email}"))
+	r.Handle("DELETE", "/users", handlers.Deprecated(handlers.DeleteUser, "/users/{email}"))
+	r.Handle("GET", "/users/stats", handlers.GetUserStats)
+	r.Handle("GET", "/users/groups", handlers.Deprecated(handlers.GetUserGroups, "/users/{email}/groups"))
+	r.Handle("GET", "/users/{email}", handlers.GetUser)
+	r.Handle("PUT", "/users/{email}", handlers.UpdateUser)
+	r.Handle("DELETE", "/users/{email}", handlers.DeleteUser)
+	r.Handle("GET", "/users/{email}/groups", handlers.GetUserGroups)
+
+	// Addresses of users.
+	r.Handle("GET", "/users/{email}/addresses", handlers.GetAddresses)
+	r.Handle("POST", "/users/{email}/addresses", handlers.CreateAddress)
+	r.Handle("GET", "/users/{email}/addresses/{id}", handlers.GetAddress)
+	r.Handle("PUT", "/users/{email}/addresses/{id}", handlers.UpdateAddress)
+	r.Handle("DELETE", "/users/{email}/addresses/{id}", handlers.DeleteAddress)
+
+	// Groups.
+	r.Handle("GET", "/groups", getGroups)
+	r.Handle("POST", "/groups", handlers.CreateGroup)
+	r.Handle("PUT", "/groups", handlers.Deprecated(handlers.UpdateGroup, "/groups/{id}"))
+	r.Handle("DELETE", "/groups", handlers.Deprecated(handlers.DeleteGroup, "/groups/{id}"))
+	r.Handle("GET", "/groups/members", handlers.Deprecated(handlers.GetGroupMembers, "/groups/{id}/members"))
+	r.Handle("POST", "/groups/members", handlers.Deprecated(handlers.AddGroupMember, "/groups/{id}/members"))
+	r.Handle("DELETE", "/groups/members", handlers.Deprecated(handlers.RemoveGroupMember, "/groups/{id}/members/{email}"))
+	r.Handle("GET", "/groups/{id}", handlers.GetGroup)
+	r.Handle("PUT", "/groups/{id}", handlers.UpdateGroup)
+	r.Handle("DELETE", "/groups/{id}", handlers.DeleteGroup)
+	r.Handle("GET", "/groups/{id}/members", handlers.GetGroupMembers)
+	r.Handle("POST", "/groups/{id}/members", handlers.AddGroupMember)
+	r.Handle("DELETE", "/groups/{id}/members/{email}", handlers.RemoveGroupMember)
+
+	return r
+}
+
+// getUsers lists users, or gets user of deprecated "email" query parameter.
+func getUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if _, ok := request.QueryStringParameters["email"]; ok {
+		return handlers.Deprecated(handlers.GetUser, "/users/{email}")(ctx, request)
+	}
+	return handlers.GetUsers(ctx, request)
+}
+
+// getGroups lists groups, or gets group of deprecated "id" query parameter.
+func getGroups(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if _, ok := request.QueryStringParameters["id"]; ok {
+		return handlers.Deprecated(handlers.GetGroup, "/groups/{id}")(ctx, request)
 	}
+	return handlers.GetGroups(ctx, request)
 }
 
 func main() {
-	lambda.Start(HandleRequest)
+	flag.Parse()
+	if *memory {
+		repository.SetDefault(repository.NewMemory())
+	}
+
+	// Serve HTTP requests locally with the same router and handlers.
+	if *httpAddress != "" {
+		logging.Printf("serving HTTP requests on %v", *httpAddress)
+		log.Fatal(http.ListenAndServe(*httpAddress, adapter.HTTPHandler(router.Route)))
+	}
+
+	// Accept REST API, HTTP API, Function URL and ALB events.
+	lambda.Start(adapter.Handler(router.Route))
 }
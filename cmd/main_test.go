@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/handlers"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRecoveryBehindMiddleware tests the router of all endpoints to ensure panics of handlers
+// are responded with 500 status code carrying CORS headers, X-Request-ID header and problem
+// details encoded in the media type the client accepts.
+func TestRecoveryBehindMiddleware(t *testing.T) {
+	policy := handlers.DefaultCORSPolicy
+	policy.AllowedOrigins = []string{"https://app.example.com"}
+	assert.NoError(t, handlers.SetCORSPolicy(policy))
+	defer handlers.SetCORSPolicy(handlers.DefaultCORSPolicy)
+
+	r := newRouter()
+	r.Handle("GET", "/panic", func(_ context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		var u *models.User
+		return &events.APIGatewayProxyResponse{Body: u.Email}, nil
+	})
+
+	actual, err := r.Route(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/panic",
+		Headers:    map[string]string{"Origin": "https://app.example.com", "Accept": "application/yaml"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "user-1"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, actual.StatusCode)
+	assert.Equal(t, "https://app.example.com", actual.Headers["Access-Control-Allow-Origin"])
+	assert.Contains(t, actual.Headers["Access-Control-Expose-Headers"], handlers.RequestIDHeader)
+	assert.Equal(t, "c6af9ac6-7b61-11e6-9a41-93e8deadbeef", actual.Headers[handlers.RequestIDHeader])
+	assert.Contains(t, actual.Headers[handlers.ContentTypeHeader], "application/yaml")
+	assert.Contains(t, actual.Body, "instance: c6af9ac6-7b61-11e6-9a41-93e8deadbeef")
+}
+
+// TestRecoveryOfMiddleware tests the router of all endpoints to ensure panics of middleware
+// outside recovery of handlers (e.g. of an encoder used by content negotiation) are responded
+// with 500 status code and problem details.
+func TestRecoveryOfMiddleware(t *testing.T) {
+	handlers.RegisterEncoder("application/x-panic", func(_ any) ([]byte, error) {
+		panic("encoder failed")
+	})
+
+	r := newRouter()
+	r.Handle("GET", "/ok", func(_ context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{handlers.ContentTypeHeader: handlers.MediaTypeJSON},
+			Body:       `{"ok":true}`,
+		}, nil
+	})
+
+	actual, err := r.Route(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/ok",
+		Headers:    map[string]string{"Accept": "application/x-panic"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "user-1"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, actual.StatusCode)
+	assert.Equal(t, handlers.MediaTypeProblemJSON, actual.Headers[handlers.ContentTypeHeader])
+	assert.Contains(t, actual.Body, `"instance":"c6af9ac6-7b61-11e6-9a41-93e8deadbeef"`)
+}
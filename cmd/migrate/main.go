@@ -0,0 +1,110 @@
+// Migrate implements a one-off copy of users from the table keyed by email, used before the
+// single-table layout, to the table of DYNAMODB_TABLE environment variable, e.g.
+// "DYNAMODB_TABLE=de07-api go run ./cmd/migrate -source de07-user". Users are written like
+// the API writes them (including encryption), so copying again overwrites them with the
+// same items.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+)
+
+var (
+	source = flag.String("source", "", "table keyed by email to copy users from (e.g. de07-user)")
+	dryRun = flag.Bool("dry-run", false, "count users to copy without writing them")
+
+	ErrorNoSourceTable   = errors.New("no source table")
+	ErrorSameTable       = errors.New("source table is the destination table")
+	ErrorFailedToScan    = errors.New("failed to scan source table")
+	ErrorFailedToMigrate = errors.New("failed to migrate user")
+)
+
+// migrateItems writes users of legacy items to the default repository and returns their
+// number. Items of the single-table layout (with PK attribute) are skipped, so the copy can
+// be repeated.
+func migrateItems(ctx context.Context, items []map[string]types.AttributeValue, dryRun bool) (int, error) {
+	migrated := 0
+	for _, item := range items {
+		if _, ok := item[repository.PartitionKeyAttribute]; ok {
+			continue
+		}
+		var u models.User
+		if err := attributevalue.UnmarshalMap(item, &u); err != nil {
+			logging.Printf("%v: %v", ErrorFailedToMigrate, err)
+			return migrated, fmt.Errorf("%w: %w", ErrorFailedToMigrate, err)
+		}
+		if u.Email == "" {
+			logging.Printf("%v: item without email", ErrorFailedToMigrate)
+			return migrated, fmt.Errorf("%w: item without email", ErrorFailedToMigrate)
+		}
+		if !dryRun {
+			if err := user.CreateUser(ctx, u); err != nil {
+				logging.Printf("%v: %v, %v", ErrorFailedToMigrate, u.Email, err)
+				return migrated, fmt.Errorf("%w: %w", ErrorFailedToMigrate, err)
+			}
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// migrate scans source table page by page and writes its users to the default repository.
+func migrate(ctx context.Context, client *dynamodb.Client, source string, dryRun bool) (int, error) {
+	migrated := 0
+	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{TableName: aws.String(source)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			logging.Printf("%v: %v", ErrorFailedToScan, err)
+			return migrated, fmt.Errorf("%w: %w", ErrorFailedToScan, err)
+		}
+		n, err := migrateItems(ctx, page.Items, dryRun)
+		migrated += n
+		if err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+func main() {
+	flag.Parse()
+	if *source == "" {
+		logging.Fatalf("%v: set -source flag", ErrorNoSourceTable)
+	}
+	if *source == os.Getenv("DYNAMODB_TABLE") {
+		logging.Fatalf("%v: %v", ErrorSameTable, *source)
+	}
+
+	// Create client of the source table, with the same endpoint as the destination one.
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		logging.Fatalf("%v: %v", repository.ErrorFailedToLoadAWSConfig, err)
+	}
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	migrated, err := migrate(ctx, client, *source, *dryRun)
+	logging.Printf("migrated %v users from %v (dry run: %v)", migrated, *source, *dryRun)
+	if err != nil {
+		os.Exit(1)
+	}
+}
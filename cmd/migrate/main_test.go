@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMigrateItems tests the migrateItems function to ensure users of items keyed by email
+// are written in the single-table layout, items of the single-table layout are skipped, dry
+// runs write nothing and items without email are rejected.
+func TestMigrateItems(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	u := testutil.ValidUser1
+	legacy := map[string]types.AttributeValue{
+		"email":     &types.AttributeValueMemberS{Value: u.Email},
+		"firstName": &types.AttributeValueMemberS{Value: u.FirstName},
+		"lastName":  &types.AttributeValueMemberS{Value: u.LastName},
+		"age":       &types.AttributeValueMemberN{Value: "37"},
+	}
+	migrated := repository.EntityKey(context.TODO(), repository.EntityUser, "jane@example.com").Attributes()
+	items := []map[string]types.AttributeValue{legacy, migrated}
+
+	n, err := migrateItems(context.TODO(), items, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = user.FetchUser(context.TODO(), u.Email)
+	assert.ErrorIs(t, err, user.ErrorUserDoesNotExist)
+
+	for range 2 {
+		n, err = migrateItems(context.TODO(), items, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+	actual, err := user.FetchUser(context.TODO(), u.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, u.Email, actual.Email)
+		assert.Equal(t, u.FirstName, actual.FirstName)
+		assert.Equal(t, 37, actual.Age)
+	}
+
+	_, err = migrateItems(context.TODO(), []map[string]types.AttributeValue{{"age": legacy["age"]}}, false)
+	assert.ErrorIs(t, err, ErrorFailedToMigrate)
+}
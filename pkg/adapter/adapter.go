@@ -0,0 +1,474 @@
+// Adapter implements conversion of API Gateway HTTP API (payload format 2.0), Lambda
+// Function URL and Application Load Balancer events into API Gateway REST API proxy
+// requests, the form handlers consume, and of their responses back into responses of the
+// invoking service.
+package adapter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/handlers"
+)
+
+// Format is the format of events invoking the Lambda function.
+type Format string
+
+const (
+	// FormatRESTAPI is the API Gateway REST API proxy event (and HTTP API payload format 1.0).
+	FormatRESTAPI Format = "REST API"
+	// FormatHTTPAPI is the API Gateway HTTP API event of payload format 2.0.
+	FormatHTTPAPI Format = "HTTP API"
+	// FormatFunctionURL is the Lambda Function URL event.
+	FormatFunctionURL Format = "Function URL"
+	// FormatALB is the Application Load Balancer target group event.
+	FormatALB Format = "ALB"
+)
+
+var (
+	ErrorUnsupportedEvent = errors.New("unsupported event")
+	ErrorInvalidBody      = errors.New("invalid base64 body")
+)
+
+// probe holds fields of events telling their formats apart.
+type probe struct {
+	Version        string `json:"version"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		ELB        *struct{} `json:"elb"`
+		DomainName string    `json:"domainName"`
+		HTTP       struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// Detect returns format of event.
+func Detect(event json.RawMessage) (Format, error) {
+	var p probe
+	if err := json.Unmarshal(event, &p); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrorUnsupportedEvent, err)
+	}
+
+	switch {
+	case p.RequestContext.ELB != nil:
+		return FormatALB, nil
+	case p.Version == "2.0" && p.RequestContext.HTTP.Method != "":
+		// Function URLs are served from "<url-id>.lambda-url.<region>.on.aws".
+		if strings.Contains(p.RequestContext.DomainName, ".lambda-url.") {
+			return FormatFunctionURL, nil
+		}
+		return FormatHTTPAPI, nil
+	case p.HTTPMethod != "":
+		return FormatRESTAPI, nil
+	}
+	return "", ErrorUnsupportedEvent
+}
+
+// Handler returns Lambda handler accepting events of all formats, handling them with
+// handler and responding with response of the format of the event.
+func Handler(handler handlers.HandlerFunc) func(context.Context, json.RawMessage) (any, error) {
+	return func(ctx context.Context, event json.RawMessage) (any, error) {
+		format, err := Detect(event)
+		if err != nil {
+			return nil, err
+		}
+
+		switch format {
+		case FormatHTTPAPI:
+			var e events.APIGatewayV2HTTPRequest
+			if err := json.Unmarshal(event, &e); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrorUnsupportedEvent, err)
+			}
+			return HTTPAPI(handler)(ctx, e)
+		case FormatFunctionURL:
+			var e events.LambdaFunctionURLRequest
+			if err := json.Unmarshal(event, &e); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrorUnsupportedEvent, err)
+			}
+			return FunctionURL(handler)(ctx, e)
+		case FormatALB:
+			var e events.ALBTargetGroupRequest
+			if err := json.Unmarshal(event, &e); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrorUnsupportedEvent, err)
+			}
+			return ALB(handler)(ctx, e)
+		default:
+			var e events.APIGatewayProxyRequest
+			if err := json.Unmarshal(event, &e); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrorUnsupportedEvent, err)
+			}
+			return RESTAPI(handler)(ctx, e)
+		}
+	}
+}
+
+// RESTAPI returns Lambda handler of API Gateway REST API events.
+func RESTAPI(handler handlers.HandlerFunc) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, e events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if err := decodeBody(&e.Body, &e.IsBase64Encoded); err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		response, err := handler(ctx, e)
+		if response == nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		return *response, err
+	}
+}
+
+// HTTPAPI returns Lambda handler of API Gateway HTTP API events.
+func HTTPAPI(handler handlers.HandlerFunc) func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return func(ctx context.Context, e events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		request, err := FromHTTPAPI(e)
+		if err != nil {
+			return events.APIGatewayV2HTTPResponse{}, err
+		}
+		response, err := handler(ctx, request)
+		if response == nil {
+			return events.APIGatewayV2HTTPResponse{}, err
+		}
+		return ToHTTPAPI(*response), err
+	}
+}
+
+// FunctionURL returns Lambda handler of Lambda Function URL events.
+func FunctionURL(handler handlers.HandlerFunc) func(context.Context, events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	return func(ctx context.Context, e events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+		request, err := FromFunctionURL(e)
+		if err != nil {
+			return events.LambdaFunctionURLResponse{}, err
+		}
+		response, err := handler(ctx, request)
+		if response == nil {
+			return events.LambdaFunctionURLResponse{}, err
+		}
+		return ToFunctionURL(*response), err
+	}
+}
+
+// ALB returns Lambda handler of Application Load Balancer target group events. Responses
+// use multi-value headers if the target group has them enabled, as ALB requires.
+func ALB(handler handlers.HandlerFunc) func(context.Context, events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	return func(ctx context.Context, e events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+		request, err := FromALB(e)
+		if err != nil {
+			return events.ALBTargetGroupResponse{}, err
+		}
+		response, err := handler(ctx, request)
+		if response == nil {
+			return events.ALBTargetGroupResponse{}, err
+		}
+		return ToALB(*response, e.MultiValueHeaders != nil), err
+	}
+}
+
+// FromHTTPAPI converts API Gateway HTTP API event to proxy request. Resource is taken from
+// the route key (e.g. "GET /users/{email}"), JWT claims and Lambda authorizer context are
+// passed as claims and context of REST API authorizers.
+func FromHTTPAPI(e events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyRequest, error) {
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:     e.RequestContext.HTTP.Method,
+		Path:           e.RawPath,
+		PathParameters: e.PathParameters,
+		StageVariables: e.StageVariables,
+		Body:           e.Body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:    e.RequestContext.AccountID,
+			RequestID:    e.RequestContext.RequestID,
+			Stage:        e.RequestContext.Stage,
+			DomainName:   e.RequestContext.DomainName,
+			DomainPrefix: e.RequestContext.DomainPrefix,
+			APIID:        e.RequestContext.APIID,
+			HTTPMethod:   e.RequestContext.HTTP.Method,
+			Path:         e.RequestContext.HTTP.Path,
+			Protocol:     e.RequestContext.HTTP.Protocol,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  e.RequestContext.HTTP.SourceIP,
+				UserAgent: e.RequestContext.HTTP.UserAgent,
+			},
+		},
+	}
+	if _, resource, ok := strings.Cut(e.RouteKey, " "); ok {
+		request.Resource = resource
+	}
+	if request.Path == "" {
+		request.Path = e.RequestContext.HTTP.Path
+	}
+	setHeaders(&request, e.Headers, e.Cookies)
+	setQuery(&request, e.RawQueryString, e.QueryStringParameters)
+
+	if a := e.RequestContext.Authorizer; a != nil {
+		authorizer := map[string]interface{}{}
+		for k, v := range a.Lambda {
+			authorizer[k] = v
+		}
+		if a.JWT != nil {
+			claims := map[string]interface{}{}
+			for k, v := range a.JWT.Claims {
+				claims[k] = v
+			}
+			authorizer["claims"] = claims
+		}
+		if a.IAM != nil {
+			request.RequestContext.Identity.AccessKey = a.IAM.AccessKey
+			request.RequestContext.Identity.AccountID = a.IAM.AccountID
+			request.RequestContext.Identity.Caller = a.IAM.CallerID
+			request.RequestContext.Identity.UserArn = a.IAM.UserARN
+			request.RequestContext.Identity.User = a.IAM.UserID
+		}
+		request.RequestContext.Authorizer = authorizer
+	}
+
+	request.IsBase64Encoded = e.IsBase64Encoded
+	if err := decodeBody(&request.Body, &request.IsBase64Encoded); err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+	return request, nil
+}
+
+// FromFunctionURL converts Lambda Function URL event to proxy request. Function URLs
+// have no routes, so resource is left empty and requests are routed by path.
+func FromFunctionURL(e events.LambdaFunctionURLRequest) (events.APIGatewayProxyRequest, error) {
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: e.RequestContext.HTTP.Method,
+		Path:       e.RawPath,
+		Body:       e.Body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:    e.RequestContext.AccountID,
+			RequestID:    e.RequestContext.RequestID,
+			DomainName:   e.RequestContext.DomainName,
+			DomainPrefix: e.RequestContext.DomainPrefix,
+			APIID:        e.RequestContext.APIID,
+			HTTPMethod:   e.RequestContext.HTTP.Method,
+			Path:         e.RequestContext.HTTP.Path,
+			Protocol:     e.RequestContext.HTTP.Protocol,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  e.RequestContext.HTTP.SourceIP,
+				UserAgent: e.RequestContext.HTTP.UserAgent,
+			},
+		},
+	}
+	if request.Path == "" {
+		request.Path = e.RequestContext.HTTP.Path
+	}
+	setHeaders(&request, e.Headers, e.Cookies)
+	setQuery(&request, e.RawQueryString, e.QueryStringParameters)
+
+	if a := e.RequestContext.Authorizer; a != nil && a.IAM != nil {
+		request.RequestContext.Identity.AccessKey = a.IAM.AccessKey
+		request.RequestContext.Identity.AccountID = a.IAM.AccountID
+		request.RequestContext.Identity.Caller = a.IAM.CallerID
+		request.RequestContext.Identity.UserArn = a.IAM.UserARN
+		request.RequestContext.Identity.User = a.IAM.UserID
+	}
+
+	request.IsBase64Encoded = e.IsBase64Encoded
+	if err := decodeBody(&request.Body, &request.IsBase64Encoded); err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+	return request, nil
+}
+
+// FromALB converts Application Load Balancer target group event to proxy request. ALB
+// passes query parameters as they were sent, so they are unescaped, and sends either
+// single-value or multi-value headers and query parameters, so the other ones are filled.
+func FromALB(e events.ALBTargetGroupRequest) (events.APIGatewayProxyRequest, error) {
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: e.HTTPMethod,
+		Path:       e.Path,
+		Body:       e.Body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			HTTPMethod: e.HTTPMethod,
+			Path:       e.Path,
+		},
+	}
+
+	// Headers.
+	if e.MultiValueHeaders != nil {
+		request.Headers = map[string]string{}
+		request.MultiValueHeaders = e.MultiValueHeaders
+		for name, values := range e.MultiValueHeaders {
+			if len(values) > 0 {
+				request.Headers[name] = values[len(values)-1]
+			}
+		}
+	} else {
+		setHeaders(&request, e.Headers, nil)
+	}
+	if forwardedFor, ok := request.Headers["x-forwarded-for"]; ok {
+		client, _, _ := strings.Cut(forwardedFor, ",")
+		request.RequestContext.Identity.SourceIP = strings.TrimSpace(client)
+	}
+
+	// Query parameters.
+	multiValueQuery := e.MultiValueQueryStringParameters
+	if multiValueQuery == nil && e.QueryStringParameters != nil {
+		multiValueQuery = map[string][]string{}
+		for name, value := range e.QueryStringParameters {
+			multiValueQuery[name] = []string{value}
+		}
+	}
+	if multiValueQuery != nil {
+		request.QueryStringParameters = map[string]string{}
+		request.MultiValueQueryStringParameters = map[string][]string{}
+		for name, values := range multiValueQuery {
+			name = unescape(name)
+			for _, value := range values {
+				request.MultiValueQueryStringParameters[name] = append(request.MultiValueQueryStringParameters[name], unescape(value))
+			}
+			if len(values) > 0 {
+				request.QueryStringParameters[name] = unescape(values[len(values)-1])
+			}
+		}
+	}
+
+	request.IsBase64Encoded = e.IsBase64Encoded
+	if err := decodeBody(&request.Body, &request.IsBase64Encoded); err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+	return request, nil
+}
+
+// ToHTTPAPI converts proxy response to API Gateway HTTP API response. Payload format 2.0
+// has no multi-value headers, so they are joined, and cookies are returned separately.
+func ToHTTPAPI(r events.APIGatewayProxyResponse) events.APIGatewayV2HTTPResponse {
+	headers, cookies := joinHeaders(r.Headers, r.MultiValueHeaders)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      r.StatusCode,
+		Headers:         headers,
+		Body:            r.Body,
+		IsBase64Encoded: r.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
+
+// ToFunctionURL converts proxy response to Lambda Function URL response, like ToHTTPAPI.
+func ToFunctionURL(r events.APIGatewayProxyResponse) events.LambdaFunctionURLResponse {
+	headers, cookies := joinHeaders(r.Headers, r.MultiValueHeaders)
+	return events.LambdaFunctionURLResponse{
+		StatusCode:      r.StatusCode,
+		Headers:         headers,
+		Body:            r.Body,
+		IsBase64Encoded: r.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
+
+// ToALB converts proxy response to Application Load Balancer target group response with
+// status description (e.g. "404 Not Found"), and multi-value headers if the request had them.
+func ToALB(r events.APIGatewayProxyResponse, multiValueHeaders bool) events.ALBTargetGroupResponse {
+	response := events.ALBTargetGroupResponse{
+		StatusCode:        r.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		Body:              r.Body,
+		IsBase64Encoded:   r.IsBase64Encoded,
+	}
+
+	merged := mergeHeaders(r.Headers, r.MultiValueHeaders)
+	if multiValueHeaders {
+		response.MultiValueHeaders = merged
+		return response
+	}
+	response.Headers = map[string]string{}
+	for name, values := range merged {
+		// Single-value headers can not repeat, only the last cookie can be set.
+		if strings.EqualFold(name, "Set-Cookie") {
+			response.Headers[name] = values[len(values)-1]
+			continue
+		}
+		response.Headers[name] = strings.Join(values, ", ")
+	}
+	return response
+}
+
+// setHeaders sets headers of request, cookies of payload format 2.0 are passed in Cookie header.
+func setHeaders(request *events.APIGatewayProxyRequest, headers map[string]string, cookies []string) {
+	request.Headers = map[string]string{}
+	request.MultiValueHeaders = map[string][]string{}
+	for name, value := range headers {
+		request.Headers[name] = value
+		request.MultiValueHeaders[name] = []string{value}
+	}
+	if len(cookies) > 0 {
+		cookie := strings.Join(cookies, "; ")
+		request.Headers["cookie"] = cookie
+		request.MultiValueHeaders["cookie"] = []string{cookie}
+	}
+}
+
+// setQuery sets query parameters of request from the raw query string, values of repeated
+// parameters are kept apart like in REST API events.
+func setQuery(request *events.APIGatewayProxyRequest, rawQuery string, parameters map[string]string) {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil || len(values) == 0 {
+		request.QueryStringParameters = parameters
+		return
+	}
+
+	request.QueryStringParameters = map[string]string{}
+	request.MultiValueQueryStringParameters = map[string][]string(values)
+	for name, v := range values {
+		request.QueryStringParameters[name] = v[len(v)-1]
+	}
+}
+
+// mergeHeaders merges single-value headers into multi-value headers. Multi-value headers
+// take precedence, like in REST API.
+func mergeHeaders(headers map[string]string, multiValueHeaders map[string][]string) map[string][]string {
+	merged := map[string][]string{}
+	for name, value := range headers {
+		merged[name] = []string{value}
+	}
+	for name, values := range multiValueHeaders {
+		if len(values) > 0 {
+			merged[name] = values
+		}
+	}
+	return merged
+}
+
+// joinHeaders merges multi-value headers into headers, except Set-Cookie headers which
+// are returned as cookies.
+func joinHeaders(headers map[string]string, multiValueHeaders map[string][]string) (map[string]string, []string) {
+	joined := map[string]string{}
+	var cookies []string
+	for name, values := range mergeHeaders(headers, multiValueHeaders) {
+		if strings.EqualFold(name, "Set-Cookie") {
+			cookies = append(cookies, values...)
+			continue
+		}
+		joined[name] = strings.Join(values, ", ")
+	}
+	return joined, cookies
+}
+
+// unescape returns query parameter unescaped, or as is if it is not escaped properly.
+func unescape(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
+
+// decodeBody decodes base64 encoded body in place, handlers expect bodies as text. Bodies
+// above the size limit of handlers are left encoded rather than decoded, so handlers reject
+// them with 413 status code.
+func decodeBody(body *string, base64Encoded *bool) error {
+	if !*base64Encoded || base64.StdEncoding.DecodedLen(len(*body)) > handlers.MaxBodySize() {
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(*body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalidBody, err)
+	}
+	*body, *base64Encoded = string(b), false
+	return nil
+}
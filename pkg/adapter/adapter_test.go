@@ -0,0 +1,375 @@
+package adapter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/handlers"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// readEvent returns recorded sample event of testdata file.
+func readEvent(t *testing.T, name string) json.RawMessage {
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestHandler tests the Handler function with recorded REST API, HTTP API, Function URL
+// and ALB events to ensure each is detected, normalized so the same handler reads the same user
+// of the tenant (from header or JWT claim) with repeated query parameters kept apart, and
+// answered with response of the matching type.
+func TestHandler(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+	defer handlers.SetTenantHeaderTrusted(false)
+	handlers.SetTenantHeaderTrusted(true)
+
+	actual, _ := handlers.WithTenant(handlers.CreateUser)(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    map[string]string{handlers.TenantHeader: "acme"},
+		Body:       testutil.ValidUser,
+	})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
+	router := handlers.NewRouter()
+	router.Use(handlers.WithTenant)
+	router.Handle("GET", "/users/{email}", handlers.GetUser)
+	handler := Handler(router.Route)
+	expectedBody := `{"email":"bartlomiej.jedrol@gmail.com","age":37}`
+
+	tests := []struct {
+		name     string
+		event    string
+		format   Format
+		expected any
+	}{
+		{
+			name:     "REST API",
+			event:    "rest-api-request.json",
+			format:   FormatRESTAPI,
+			expected: events.APIGatewayProxyResponse{},
+		},
+		{
+			name:     "HTTP API",
+			event:    "http-api-request.json",
+			format:   FormatHTTPAPI,
+			expected: events.APIGatewayV2HTTPResponse{},
+		},
+		{
+			name:     "Function URL",
+			event:    "function-url-request.json",
+			format:   FormatFunctionURL,
+			expected: events.LambdaFunctionURLResponse{},
+		},
+		{
+			name:     "ALB",
+			event:    "alb-request.json",
+			format:   FormatALB,
+			expected: events.ALBTargetGroupResponse{},
+		},
+		{
+			name:     "ALB with multi-value headers",
+			event:    "alb-multi-value-request.json",
+			format:   FormatALB,
+			expected: events.ALBTargetGroupResponse{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := readEvent(t, tt.event)
+			format, err := Detect(event)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+
+			response, err := handler(context.TODO(), event)
+			if !assert.NoError(t, err) || !assert.IsType(t, tt.expected, response) {
+				return
+			}
+			switch r := response.(type) {
+			case events.APIGatewayProxyResponse:
+				assert.Equal(t, http.StatusOK, r.StatusCode)
+				assert.JSONEq(t, expectedBody, r.Body)
+			case events.APIGatewayV2HTTPResponse:
+				assert.Equal(t, http.StatusOK, r.StatusCode)
+				assert.JSONEq(t, expectedBody, r.Body)
+			case events.LambdaFunctionURLResponse:
+				assert.Equal(t, http.StatusOK, r.StatusCode)
+				assert.JSONEq(t, expectedBody, r.Body)
+			case events.ALBTargetGroupResponse:
+				assert.Equal(t, http.StatusOK, r.StatusCode)
+				assert.Equal(t, "200 OK", r.StatusDescription)
+				assert.JSONEq(t, expectedBody, r.Body)
+			}
+		})
+	}
+
+	_, err := handler(context.TODO(), json.RawMessage(`{"Records": []}`))
+	assert.ErrorIs(t, err, ErrorUnsupportedEvent)
+}
+
+// TestHandlerBodySize tests the Handler function with REST API and HTTP API events carrying
+// base64 encoded bodies to ensure bodies within the size limit are decoded, and bodies above
+// it reach handlers encoded, so they are rejected with 413 status code without decoding them.
+func TestHandlerBodySize(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+	defer handlers.SetMaxBodySize(handlers.MaxBodySize())
+	handlers.SetMaxBodySize(len(testutil.ValidUser) + 10)
+
+	var encoded bool
+	router := handlers.NewRouter()
+	router.Handle("POST", "/users", func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		encoded = request.IsBase64Encoded
+		return handlers.CreateUser(ctx, request)
+	})
+	handler := Handler(router.Route)
+	oversized := strings.Repeat(" ", handlers.MaxBodySize()) + testutil.ValidUser
+
+	tests := []struct {
+		name            string
+		event           func(body string) any
+		body            string
+		expectedEncoded bool
+		expectedStatus  int
+	}{
+		{
+			name:           "REST API body within limit",
+			event:          restAPIEvent,
+			body:           testutil.ValidUser,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:            "REST API body above limit",
+			event:           restAPIEvent,
+			body:            oversized,
+			expectedEncoded: true,
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "HTTP API body within limit",
+			event:          httpAPIEvent,
+			body:           testutil.ValidUser,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:            "HTTP API body above limit",
+			event:           httpAPIEvent,
+			body:            oversized,
+			expectedEncoded: true,
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := json.Marshal(tt.event(base64.StdEncoding.EncodeToString([]byte(tt.body))))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := handler(context.TODO(), event)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.expectedEncoded, encoded)
+			switch r := response.(type) {
+			case events.APIGatewayProxyResponse:
+				assert.Equal(t, tt.expectedStatus, r.StatusCode)
+			case events.APIGatewayV2HTTPResponse:
+				assert.Equal(t, tt.expectedStatus, r.StatusCode)
+			default:
+				t.Fatalf("unexpected response: %T", response)
+			}
+		})
+	}
+}
+
+// restAPIEvent returns REST API event creating user with base64 encoded body.
+func restAPIEvent(body string) any {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:      "POST",
+		Resource:        "/users",
+		Path:            "/users",
+		Body:            body,
+		IsBase64Encoded: true,
+	}
+}
+
+// httpAPIEvent returns HTTP API event creating user with base64 encoded body.
+func httpAPIEvent(body string) any {
+	return events.APIGatewayV2HTTPRequest{
+		Version:  "2.0",
+		RouteKey: "POST /users",
+		RawPath:  "/users",
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "POST", Path: "/users"},
+		},
+		Body:            body,
+		IsBase64Encoded: true,
+	}
+}
+
+// TestFromHTTPAPI tests the FromHTTPAPI function to ensure the recorded HTTP API event is
+// converted into proxy request with route key resource, cookies, claims and identity.
+func TestFromHTTPAPI(t *testing.T) {
+	var e events.APIGatewayV2HTTPRequest
+	if err := json.Unmarshal(readEvent(t, "http-api-request.json"), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := FromHTTPAPI(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", actual.HTTPMethod)
+	assert.Equal(t, "/users/{email}", actual.Resource)
+	assert.Equal(t, "/users/bartlomiej.jedrol%40gmail.com", actual.Path)
+	assert.Equal(t, map[string]string{"email": "bartlomiej.jedrol@gmail.com"}, actual.PathParameters)
+	assert.Equal(t, map[string]string{"fields": "email,age", "filter": "lastName=Jedrol"}, actual.QueryStringParameters)
+	assert.Equal(t, []string{"age>=18", "lastName=Jedrol"}, actual.MultiValueQueryStringParameters["filter"])
+	assert.Equal(t, "session=abc123; theme=dark", actual.Headers["cookie"])
+	assert.Equal(t, "acme", actual.RequestContext.Authorizer["claims"].(map[string]interface{})["custom:tenantId"])
+	assert.Equal(t, "203.0.113.10", actual.RequestContext.Identity.SourceIP)
+	assert.Equal(t, "Q3kBaEb1FiAEJvQ=", actual.RequestContext.RequestID)
+
+	// Base64 encoded bodies are decoded.
+	e.Body, e.IsBase64Encoded = "eyJhIjoxfQ==", true
+	actual, err = FromHTTPAPI(e)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, actual.Body)
+	assert.False(t, actual.IsBase64Encoded)
+
+	e.Body = "not base64"
+	_, err = FromHTTPAPI(e)
+	assert.ErrorIs(t, err, ErrorInvalidBody)
+}
+
+// TestFromFunctionURL tests the FromFunctionURL function to ensure the recorded Function URL
+// event is converted into proxy request routed by path.
+func TestFromFunctionURL(t *testing.T) {
+	var e events.LambdaFunctionURLRequest
+	if err := json.Unmarshal(readEvent(t, "function-url-request.json"), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := FromFunctionURL(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", actual.HTTPMethod)
+	assert.Empty(t, actual.Resource)
+	assert.Equal(t, "/users/bartlomiej.jedrol%40gmail.com", actual.Path)
+	assert.Equal(t, "acme", actual.Headers["x-tenant-id"])
+	assert.Equal(t, []string{"age>=18", "lastName=Jedrol"}, actual.MultiValueQueryStringParameters["filter"])
+}
+
+// TestToHTTPAPI tests the ToHTTPAPI and ToFunctionURL functions to ensure multi-value
+// headers are joined and Set-Cookie headers are returned as cookies.
+func TestToHTTPAPI(t *testing.T) {
+	response := events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		Headers:           map[string]string{"Content-Type": "application/json", "Vary": "Accept"},
+		MultiValueHeaders: map[string][]string{"Vary": {"Accept", "Origin"}, "Set-Cookie": {"a=1", "b=2"}},
+		Body:              `{"a":1}`,
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Vary": "Accept, Origin"}
+
+	assert.Equal(t, events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       `{"a":1}`,
+		Cookies:    []string{"a=1", "b=2"},
+	}, ToHTTPAPI(response))
+	assert.Equal(t, events.LambdaFunctionURLResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       `{"a":1}`,
+		Cookies:    []string{"a=1", "b=2"},
+	}, ToFunctionURL(response))
+}
+
+// TestFromALB tests the FromALB function to ensure query parameters of the recorded ALB
+// events are unescaped and single-value and multi-value headers are both filled.
+func TestFromALB(t *testing.T) {
+	var e events.ALBTargetGroupRequest
+	if err := json.Unmarshal(readEvent(t, "alb-multi-value-request.json"), &e); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := FromALB(e)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", actual.HTTPMethod)
+	assert.Equal(t, "/users/bartlomiej.jedrol%40gmail.com", actual.Path)
+	assert.Equal(t, map[string]string{"fields": "email,age", "filter": "lastName=Jedrol"}, actual.QueryStringParameters)
+	assert.Equal(t, []string{"age>=18", "lastName=Jedrol"}, actual.MultiValueQueryStringParameters["filter"])
+	assert.Equal(t, "acme", actual.Headers["x-tenant-id"])
+	assert.Equal(t, "10.0.1.25", actual.RequestContext.Identity.SourceIP)
+
+	e = events.ALBTargetGroupRequest{}
+	if err := json.Unmarshal(readEvent(t, "alb-request.json"), &e); err != nil {
+		t.Fatal(err)
+	}
+	actual, err = FromALB(e)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"fields": "email,age"}, actual.QueryStringParameters)
+	assert.Equal(t, []string{"acme"}, actual.MultiValueHeaders["x-tenant-id"])
+}
+
+// TestToALB tests the ToALB function to ensure responses carry status description and
+// headers of the mode of the request, including responses of unknown routes and methods.
+func TestToALB(t *testing.T) {
+	router := handlers.NewRouter()
+	router.Handle("GET", "/users", handlers.GetUsers)
+
+	tests := []struct {
+		name       string
+		request    events.ALBTargetGroupRequest
+		multiValue bool
+		expected   events.ALBTargetGroupResponse
+	}{
+		{
+			name:    "Unknown path",
+			request: events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/orders", Headers: map[string]string{}},
+			expected: events.ALBTargetGroupResponse{
+				StatusCode:        http.StatusNotFound,
+				StatusDescription: "404 Not Found",
+				Headers:           map[string]string{"Content-Type": "application/problem+json"},
+				Body:              `{"type":"` + handlers.ProblemTypeBaseURI + `not-found","title":"Resource not found","status":404}`,
+			},
+		},
+		{
+			name:       "Unsupported method with multi-value headers",
+			request:    events.ALBTargetGroupRequest{HTTPMethod: "PATCH", Path: "/users", MultiValueHeaders: map[string][]string{}},
+			multiValue: true,
+			expected: events.ALBTargetGroupResponse{
+				StatusCode:        http.StatusMethodNotAllowed,
+				StatusDescription: "405 Method Not Allowed",
+				MultiValueHeaders: map[string][]string{"Content-Type": {"application/problem+json"}, handlers.AllowHeader: {"GET"}},
+				Body:              `{"type":"` + handlers.ProblemTypeBaseURI + `method-not-allowed","title":"Method not allowed","status":405}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ALB(router.Route)(context.TODO(), tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+
+	// Only the last cookie fits single-value headers.
+	response := events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+	}
+	assert.Equal(t, map[string]string{"Set-Cookie": "b=2"}, ToALB(response, false).Headers)
+	assert.Equal(t, map[string][]string{"Set-Cookie": {"a=1", "b=2"}}, ToALB(response, true).MultiValueHeaders)
+}
@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/handlers"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+// LocalStage is the stage of requests served by HTTPHandler.
+const LocalStage = "local"
+
+// requestCount numbers requests served by HTTPHandler to give them IDs.
+var requestCount atomic.Int64
+
+// HTTPHandler returns net/http handler serving requests with handler, so the API can be
+// run and tried locally (e.g. with curl) without deploying it.
+func HTTPHandler(handler handlers.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := FromHTTP(r)
+		if err != nil {
+			logging.Printf("%v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response, err := handler(r.Context(), request)
+		if err != nil || response == nil {
+			logging.Printf("handler failed: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if err := WriteHTTP(w, *response); err != nil {
+			logging.Printf("%v", err)
+		}
+	})
+}
+
+// FromHTTP converts net/http request to proxy request like API Gateway REST API proxy
+// integration does. Path is kept escaped and routed by path, like requests of Function URLs.
+func FromHTTP(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, fmt.Errorf("%w: %w", ErrorInvalidBody, err)
+	}
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:        r.Method,
+		Path:              r.URL.EscapedPath(),
+		Headers:           map[string]string{},
+		MultiValueHeaders: map[string][]string{},
+		Body:              string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  "local-" + strconv.FormatInt(requestCount.Add(1), 10),
+			Stage:      LocalStage,
+			DomainName: r.Host,
+			HTTPMethod: r.Method,
+			Path:       r.URL.EscapedPath(),
+			Protocol:   r.Proto,
+			Identity: events.APIGatewayRequestIdentity{
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		request.RequestContext.Identity.SourceIP = host
+	}
+
+	for name, values := range r.Header {
+		request.Headers[name] = values[len(values)-1]
+		request.MultiValueHeaders[name] = values
+	}
+	if r.Host != "" {
+		request.Headers["Host"] = r.Host
+		request.MultiValueHeaders["Host"] = []string{r.Host}
+	}
+
+	if query := r.URL.Query(); len(query) > 0 {
+		request.QueryStringParameters = map[string]string{}
+		request.MultiValueQueryStringParameters = query
+		for name, values := range query {
+			request.QueryStringParameters[name] = values[len(values)-1]
+		}
+	}
+	return request, nil
+}
+
+// WriteHTTP writes proxy response to net/http response writer, or internal server error if
+// its body can not be decoded.
+func WriteHTTP(w http.ResponseWriter, r events.APIGatewayProxyResponse) error {
+	body := []byte(r.Body)
+	if r.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.Body); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return fmt.Errorf("%w: %w", ErrorInvalidBody, err)
+		}
+	}
+
+	for name, values := range mergeHeaders(r.Headers, r.MultiValueHeaders) {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(r.StatusCode)
+	_, err := w.Write(body)
+	return err
+}
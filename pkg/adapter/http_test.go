@@ -0,0 +1,133 @@
+package adapter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/handlers"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHTTPHandler tests the HTTPHandler function to ensure net/http requests are served by
+// the router and handlers over the in-memory store, with statuses, headers and bodies of
+// their responses written back.
+func TestHTTPHandler(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	router := handlers.NewRouter()
+	router.Handle("POST", "/users", handlers.CreateUser)
+	router.Handle("GET", "/users/{email}", handlers.GetUser)
+	server := httptest.NewServer(HTTPHandler(router.Route))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedHeader http.Header
+	}{
+		{
+			name:           "Create user",
+			method:         "POST",
+			path:           "/users",
+			body:           testutil.ValidUser,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   testutil.ValidUser,
+			expectedHeader: http.Header{"Content-Type": {"application/json"}},
+		},
+		{
+			name:           "Get user with escaped email and fields",
+			method:         "GET",
+			path:           "/users/bartlomiej.jedrol%40gmail.com?fields=email,age",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"age":37,"email":"bartlomiej.jedrol@gmail.com"}`,
+		},
+		{
+			name:           "Unsupported method",
+			method:         "DELETE",
+			path:           "/users",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   `{"type":"` + handlers.ProblemTypeBaseURI + `method-not-allowed","title":"Method not allowed","status":405}`,
+			expectedHeader: http.Header{handlers.AllowHeader: {"POST"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := server.Client().Do(request)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer response.Body.Close()
+
+			body, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expectedStatus, response.StatusCode)
+			assert.Equal(t, tt.expectedBody, string(body))
+			for name, values := range tt.expectedHeader {
+				assert.Equal(t, values, response.Header.Values(name))
+			}
+		})
+	}
+}
+
+// TestFromHTTP tests the FromHTTP function to ensure net/http request is converted into
+// proxy request with escaped path, last and all values of headers and query parameters.
+func TestFromHTTP(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/users/a%40b.com?filter=age%3E%3D18&filter=lastName%3DSmith", strings.NewReader(`{"age":30}`))
+	r.Header.Add("Prefer", "consistent")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+
+	actual, err := FromHTTP(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT", actual.HTTPMethod)
+	assert.Equal(t, "/users/a%40b.com", actual.Path)
+	assert.Equal(t, `{"age":30}`, actual.Body)
+	assert.Equal(t, "application/json", actual.Headers["Accept"])
+	assert.Equal(t, []string{"text/html", "application/json"}, actual.MultiValueHeaders["Accept"])
+	assert.Equal(t, "consistent", actual.Headers["Prefer"])
+	assert.Equal(t, map[string]string{"filter": "lastName=Smith"}, actual.QueryStringParameters)
+	assert.Equal(t, []string{"age>=18", "lastName=Smith"}, actual.MultiValueQueryStringParameters["filter"])
+	assert.Equal(t, LocalStage, actual.RequestContext.Stage)
+	assert.Equal(t, "192.0.2.1", actual.RequestContext.Identity.SourceIP)
+}
+
+// TestWriteHTTP tests the WriteHTTP function to ensure base64 encoded bodies are decoded
+// and multi-value headers are written as repeated headers.
+func TestWriteHTTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := WriteHTTP(w, events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		Headers:           map[string]string{"Content-Type": "text/plain"},
+		MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+		Body:              "aGVsbG8=",
+		IsBase64Encoded:   true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"a=1", "b=2"}, w.Header().Values("Set-Cookie"))
+
+	w = httptest.NewRecorder()
+	err = WriteHTTP(w, events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "!", IsBase64Encoded: true})
+	assert.ErrorIs(t, err, ErrorInvalidBody)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
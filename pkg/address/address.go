@@ -0,0 +1,243 @@
+// Address implements functions for managing postal addresses of users. Addresses are
+// stored in the partition of their user, so they are deleted together with the user.
+package address
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+	validator "github.com/go-playground/validator/v10"
+)
+
+var (
+	validate *validator.Validate
+
+	ErrorFailedToUnmarshalMap    = errors.New("failed to unmarshal map for item")
+	ErrorFailedToValidateAddress = errors.New("failed to validate address")
+	ErrorAddressDoesNotExist     = errors.New("address does not exist")
+	ErrorDefaultAddressRequired  = errors.New("default address required")
+)
+
+// countryRule holds validation rules of addresses of a single country.
+type countryRule struct {
+	postalCode     *regexp.Regexp
+	regionRequired bool
+}
+
+// countryRules holds validation rules of countries with known postal code formats.
+// Addresses of other countries are validated only against the Address struct tags.
+var countryRules = map[string]countryRule{
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), regionRequired: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`), regionRequired: true},
+	"AU": {postalCode: regexp.MustCompile(`^\d{4}$`), regionRequired: true},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`)},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"PL": {postalCode: regexp.MustCompile(`^\d{2}-\d{3}$`)},
+	"NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Za-z]{2}$`)},
+}
+
+func init() {
+	// Create validator of Address struct with country-specific rules.
+	validate = validator.New()
+	validate.RegisterStructValidation(validateCountry, models.Address{})
+}
+
+// validateCountry validates postal code and region of address against rules of its country.
+func validateCountry(sl validator.StructLevel) {
+	a := sl.Current().Interface().(models.Address)
+	rule, ok := countryRules[strings.ToUpper(a.Country)]
+	if !ok {
+		return
+	}
+
+	if !rule.postalCode.MatchString(a.PostalCode) {
+		sl.ReportError(a.PostalCode, "PostalCode", "postalCode", "postcode_"+a.Country, "")
+	}
+	if rule.regionRequired && a.Region == "" {
+		sl.ReportError(a.Region, "Region", "region", "required_for_"+a.Country, "")
+	}
+}
+
+// FetchAddress fetches address of user based on its ID.
+func FetchAddress(ctx context.Context, email string, id string) (*models.Address, error) {
+	item, err := repository.Default().Get(ctx, keyOf(ctx, email, id))
+	if errors.Is(err, repository.ErrorItemNotFound) {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorAddressDoesNotExist, email, id)
+		return nil, fmt.Errorf("%w: %v, %v", ErrorAddressDoesNotExist, email, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalAddress(ctx, item)
+}
+
+// FetchAddresses fetches all addresses of user ordered by ID.
+func FetchAddresses(ctx context.Context, email string) ([]models.Address, error) {
+	// Check for user existence, so unknown users are not reported as users without addresses.
+	_, err := user.FetchUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := repository.PartitionKey(ctx, repository.EntityUser, email)
+	items, err := repository.Default().Query(ctx, pk, repository.SortKeyPrefix(repository.EntityAddress))
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []models.Address
+	for _, item := range items {
+		a, err := unmarshalAddress(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, *a)
+	}
+	logging.PrintfContext(ctx, "addresses: %v", len(addresses))
+
+	return addresses, nil
+}
+
+// CreateAddress creates or overwrites address of existing user. The first address of
+// the user becomes the default one, and a new default address clears the default flag
+// of the other addresses of the user in the same transaction. The default flag can't be
+// cleared, another address has to become the default one instead. It returns the stored
+// address.
+func CreateAddress(ctx context.Context, email string, address models.Address) (*models.Address, error) {
+	// Validate address struct with country-specific rules.
+	err := validate.Struct(address)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToValidateAddress, address, err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToValidateAddress, err)
+	}
+
+	addresses, err := FetchAddresses(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep a single default address.
+	var writes []repository.Write
+	others := 0
+	for _, a := range addresses {
+		if a.ID == address.ID {
+			if a.Default && !address.Default {
+				logging.PrintfContext(ctx, "%v: %v, %v", ErrorDefaultAddressRequired, email, address.ID)
+				return nil, fmt.Errorf("%w: %v, %v", ErrorDefaultAddressRequired, email, address.ID)
+			}
+			continue
+		}
+		others++
+		if address.Default && a.Default {
+			a.Default = false
+			writes = append(writes, repository.Write{Put: addressItem(ctx, email, a)})
+		}
+	}
+	if others == 0 {
+		address.Default = true
+	}
+
+	writes = append(writes, repository.Write{Put: addressItem(ctx, email, address)})
+	err = repository.Default().Transact(ctx, writes...)
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// UpdateAddress overwrites existing address of user and returns the stored address.
+func UpdateAddress(ctx context.Context, email string, address models.Address) (*models.Address, error) {
+	err := validate.Struct(address)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToValidateAddress, address, err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToValidateAddress, err)
+	}
+
+	_, err = FetchAddress(ctx, email, address.ID)
+	if err != nil {
+		return nil, err // Bypassing error from the FetchAddress function to the caller to build response.
+	}
+
+	return CreateAddress(ctx, email, address)
+}
+
+// DeleteAddress deletes address of user and returns it. If the default address is deleted,
+// the first remaining address of the user (by ID) becomes the default one in the same
+// transaction.
+func DeleteAddress(ctx context.Context, email string, id string) (*models.Address, error) {
+	deleted, err := FetchAddress(ctx, email, id)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted.Default {
+		_, err := repository.Default().Delete(ctx, keyOf(ctx, email, id))
+		if errors.Is(err, repository.ErrorItemNotFound) {
+			logging.PrintfContext(ctx, "%v: %v, %v", ErrorAddressDoesNotExist, email, id)
+			return nil, fmt.Errorf("%w: %v, %v", ErrorAddressDoesNotExist, email, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return deleted, nil
+	}
+
+	// Promote another address to the default one.
+	addresses, err := FetchAddresses(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	writes := []repository.Write{{Delete: keyOf(ctx, email, id)}}
+	for _, a := range addresses {
+		if a.ID != id {
+			a.Default = true
+			writes = append(writes, repository.Write{Put: addressItem(ctx, email, a)})
+			break
+		}
+	}
+	if err := repository.Default().Transact(ctx, writes...); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// keyOf returns key of address item in the partition of the user.
+func keyOf(ctx context.Context, email string, id string) repository.Key {
+	return repository.ChildKey(ctx, repository.EntityUser, email, repository.EntityAddress, id)
+}
+
+// addressItem returns address item with all attributes.
+func addressItem(ctx context.Context, email string, a models.Address) repository.Item {
+	item := repository.NewItem(ctx, keyOf(ctx, email, a.ID), repository.EntityAddress)
+	item["id"] = &types.AttributeValueMemberS{Value: a.ID}
+	item["type"] = &types.AttributeValueMemberS{Value: a.Type}
+	item["default"] = &types.AttributeValueMemberBOOL{Value: a.Default}
+	item["line1"] = &types.AttributeValueMemberS{Value: a.Line1}
+	item["line2"] = &types.AttributeValueMemberS{Value: a.Line2}
+	item["city"] = &types.AttributeValueMemberS{Value: a.City}
+	item["region"] = &types.AttributeValueMemberS{Value: a.Region}
+	item["postalCode"] = &types.AttributeValueMemberS{Value: a.PostalCode}
+	item["country"] = &types.AttributeValueMemberS{Value: a.Country}
+	return item
+}
+
+// unmarshalAddress extracts address from DynamoDB item.
+func unmarshalAddress(ctx context.Context, item repository.Item) (*models.Address, error) {
+	var a models.Address
+	err := attributevalue.UnmarshalMap(item, &a)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToUnmarshalMap, repository.KeyOf(item), err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToUnmarshalMap, err)
+	}
+	return &a, nil
+}
@@ -0,0 +1,249 @@
+package address
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+	"github.com/stretchr/testify/assert"
+)
+
+// setup replaces the default repository with in-memory one holding a single user.
+func setup(t *testing.T) context.Context {
+	t.Helper()
+	previous := repository.SetDefault(repository.NewMemory())
+	t.Cleanup(func() { repository.SetDefault(previous) })
+
+	ctx := context.TODO()
+	assert.NoError(t, user.CreateUser(ctx, testutil.ValidUser1))
+	return ctx
+}
+
+// TestValidate tests the validator of Address struct to ensure it accepts valid addresses
+// and rejects addresses with invalid type, country, or postal code and region violating
+// rules of the country.
+func TestValidate(t *testing.T) {
+	modify := func(f func(a *models.Address)) models.Address {
+		a := testutil.ValidAddress1
+		f(&a)
+		return a
+	}
+
+	tests := []struct {
+		name          string
+		address       models.Address
+		expectedValid bool
+	}{
+		{
+			name:          "Valid US address",
+			address:       testutil.ValidAddress1,
+			expectedValid: true,
+		},
+		{
+			name:          "Valid PL address without region",
+			address:       testutil.ValidAddress2,
+			expectedValid: true,
+		},
+		{
+			name:          "Country without rules",
+			address:       modify(func(a *models.Address) { a.Country, a.PostalCode, a.Region = "IE", "", "" }),
+			expectedValid: true,
+		},
+		{
+			name:          "Invalid type",
+			address:       modify(func(a *models.Address) { a.Type = "summer" }),
+			expectedValid: false,
+		},
+		{
+			name:          "Invalid country",
+			address:       modify(func(a *models.Address) { a.Country = "XX" }),
+			expectedValid: false,
+		},
+		{
+			name:          "Invalid US postal code",
+			address:       modify(func(a *models.Address) { a.PostalCode = "00-001" }),
+			expectedValid: false,
+		},
+		{
+			name:          "Missing US region",
+			address:       modify(func(a *models.Address) { a.Region = "" }),
+			expectedValid: false,
+		},
+		{
+			name:          "Missing line",
+			address:       modify(func(a *models.Address) { a.Line1 = "" }),
+			expectedValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.Struct(tt.address)
+			assert.Equal(t, tt.expectedValid, err == nil, err)
+		})
+	}
+}
+
+// TestAddresses tests the CreateAddress, FetchAddresses, UpdateAddress and DeleteAddress
+// functions to ensure addresses are stored per user with a single default address, and that
+// addresses of non-existing users or non-existing addresses are reported.
+func TestAddresses(t *testing.T) {
+	ctx := setup(t)
+
+	// First address becomes default.
+	work := testutil.ValidAddress2
+	a, err := CreateAddress(ctx, testutil.ValidUser1.Email, work)
+	if assert.NoError(t, err) {
+		assert.True(t, a.Default)
+	}
+	work.Default = true
+
+	// New default address clears the default flag of the others.
+	_, err = CreateAddress(ctx, testutil.ValidUser1.Email, testutil.ValidAddress1)
+	assert.NoError(t, err)
+	work.Default = false
+	addresses, err := FetchAddresses(ctx, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.Address{testutil.ValidAddress1, work}, addresses)
+	}
+
+	// Update.
+	work.Line2 = "Floor 2"
+	a, err = UpdateAddress(ctx, testutil.ValidUser1.Email, work)
+	if assert.NoError(t, err) {
+		assert.Equal(t, work, *a)
+	}
+	_, err = UpdateAddress(ctx, testutil.ValidUser1.Email, models.Address{
+		ID: "billing", Type: "billing", Line1: "1", City: "Dublin", Country: "IE",
+	})
+	assert.ErrorIs(t, err, ErrorAddressDoesNotExist)
+
+	// Invalid user or address.
+	_, err = CreateAddress(ctx, testutil.InvalidUser1.Email, testutil.ValidAddress1)
+	assert.ErrorIs(t, err, user.ErrorUserDoesNotExist)
+	_, err = FetchAddresses(ctx, testutil.InvalidUser1.Email)
+	assert.ErrorIs(t, err, user.ErrorUserDoesNotExist)
+	_, err = CreateAddress(ctx, testutil.ValidUser1.Email, models.Address{ID: "home"})
+	assert.ErrorIs(t, err, ErrorFailedToValidateAddress)
+
+	// Delete.
+	a, err = DeleteAddress(ctx, testutil.ValidUser1.Email, work.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, work, *a)
+	}
+	_, err = FetchAddress(ctx, testutil.ValidUser1.Email, work.ID)
+	assert.ErrorIs(t, err, ErrorAddressDoesNotExist)
+	_, err = DeleteAddress(ctx, testutil.ValidUser1.Email, work.ID)
+	assert.ErrorIs(t, err, ErrorAddressDoesNotExist)
+}
+
+// TestDefaultAddress tests the UpdateAddress and DeleteAddress functions to ensure a user with
+// addresses keeps exactly one default address: clearing the default flag of the default
+// address is rejected, and deleting the default address promotes another one.
+func TestDefaultAddress(t *testing.T) {
+	ctx := setup(t)
+	email := testutil.ValidUser1.Email
+
+	home, work := testutil.ValidAddress1, testutil.ValidAddress2
+	for _, a := range []models.Address{work, home} {
+		_, err := CreateAddress(ctx, email, a)
+		assert.NoError(t, err)
+	}
+
+	// Clearing the default flag of the default address.
+	home.Default = false
+	_, err := UpdateAddress(ctx, email, home)
+	assert.ErrorIs(t, err, ErrorDefaultAddressRequired)
+	a, err := FetchAddress(ctx, email, home.ID)
+	if assert.NoError(t, err) {
+		assert.True(t, a.Default)
+	}
+
+	// Deleting the default address.
+	_, err = DeleteAddress(ctx, email, home.ID)
+	assert.NoError(t, err)
+	work.Default = true
+	addresses, err := FetchAddresses(ctx, email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.Address{work}, addresses)
+	}
+
+	// Deleting the last address.
+	_, err = DeleteAddress(ctx, email, work.ID)
+	assert.NoError(t, err)
+	addresses, err = FetchAddresses(ctx, email)
+	if assert.NoError(t, err) {
+		assert.Empty(t, addresses)
+	}
+}
+
+// failingRepository fails transactions of more than one write, as DynamoDB cancels the whole
+// transaction when its second write fails.
+type failingRepository struct {
+	repository.Repository
+}
+
+var errFailedWrite = errors.New("failed write")
+
+func (r failingRepository) Transact(ctx context.Context, writes ...repository.Write) error {
+	if len(writes) > 1 {
+		return errFailedWrite
+	}
+	return r.Repository.Transact(ctx, writes...)
+}
+
+// TestDefaultAddressFailedWrite tests the CreateAddress and DeleteAddress functions to ensure
+// a failing write of the default flag of another address leaves all addresses unchanged,
+// so the user is never left with no default address or with two of them.
+func TestDefaultAddressFailedWrite(t *testing.T) {
+	ctx := setup(t)
+	email := testutil.ValidUser1.Email
+
+	home, work := testutil.ValidAddress1, testutil.ValidAddress2
+	for _, a := range []models.Address{home, work} {
+		_, err := CreateAddress(ctx, email, a)
+		assert.NoError(t, err)
+	}
+	repository.SetDefault(failingRepository{repository.Default()})
+
+	// Making another address the default one.
+	work.Default = true
+	_, err := UpdateAddress(ctx, email, work)
+	assert.ErrorIs(t, err, errFailedWrite)
+
+	// Deleting the default address.
+	_, err = DeleteAddress(ctx, email, home.ID)
+	assert.ErrorIs(t, err, errFailedWrite)
+
+	work.Default = false
+	addresses, err := FetchAddresses(ctx, email)
+	if assert.NoError(t, err) {
+		assert.ElementsMatch(t, []models.Address{home, work}, addresses)
+	}
+}
+
+// TestDeleteUserCascade tests that deleting a user with user.DeleteUser deletes the user's
+// addresses, so they do not reappear when the user is created again.
+func TestDeleteUserCascade(t *testing.T) {
+	ctx := setup(t)
+
+	for _, a := range []models.Address{testutil.ValidAddress1, testutil.ValidAddress2} {
+		_, err := CreateAddress(ctx, testutil.ValidUser1.Email, a)
+		assert.NoError(t, err)
+	}
+
+	_, err := user.DeleteUser(ctx, testutil.ValidUser1.Email)
+	assert.NoError(t, err)
+	_, err = FetchAddress(ctx, testutil.ValidUser1.Email, testutil.ValidAddress1.ID)
+	assert.ErrorIs(t, err, ErrorAddressDoesNotExist)
+
+	assert.NoError(t, user.CreateUser(ctx, testutil.ValidUser1))
+	addresses, err := FetchAddresses(ctx, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Empty(t, addresses)
+	}
+}
@@ -0,0 +1,146 @@
+// Cache implements a bounded in-memory LRU cache with expiring entries. It lives as long
+// as the warm Lambda container, so it only ever sees writes made in the same container.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Stats holds counters of cache lookups since the cache was created.
+type Stats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// LRU is a cache of at most capacity entries evicting the least recently used entry
+// first. A nil *LRU is a valid, always empty cache.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[K]*list.Element
+	order    *list.List
+	stats    Stats
+	now      func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New returns an empty cache of capacity entries or nil if capacity is not positive.
+func New[K comparable, V any](capacity int) *LRU[K, V] {
+	if capacity <= 0 {
+		return nil
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		entries:  map[K]*list.Element{},
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// Get returns value of key if it is cached and has not expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+	e := element.Value.(*entry[K, V])
+	if !c.now().Before(e.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		c.stats.Misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+	return e.value, true
+}
+
+// Set caches value of key for ttl, evicting the least recently used entry if the cache is full.
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if element, ok := c.entries[key]; ok {
+		element.Value = &entry[K, V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[K, V]).key)
+		c.stats.Evictions++
+	}
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+}
+
+// Delete removes key from the cache.
+func (c *LRU[K, V]) Delete(key K) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of cached entries including expired ones not evicted yet.
+func (c *LRU[K, V]) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns counters of cache lookups.
+func (c *LRU[K, V]) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+type bypassKey struct{}
+
+// WithBypass returns a copy of ctx instructing readers to skip cached values and read
+// through to the storage (e.g. for requests with "Cache-Control: no-cache").
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// Bypassed reports whether ctx instructs readers to skip cached values.
+func Bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
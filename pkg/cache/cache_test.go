@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLRU tests the LRU cache to ensure it returns cached values until they expire,
+// evicts the least recently used entry when full, and counts hits, misses and evictions.
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := New[string, int](2)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Second)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// "b" is the least recently used entry.
+	c.Set("c", 3, time.Minute)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	// Expired entries are misses.
+	now = now.Add(time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("c", 4, time.Minute)
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	c.Delete("c")
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+
+	assert.Equal(t, Stats{Hits: 2, Misses: 3, Evictions: 1}, c.Stats())
+	assert.Equal(t, 0, c.Len())
+}
+
+// TestDisabledLRU tests that a cache without capacity is nil and never caches values.
+func TestDisabledLRU(t *testing.T) {
+	c := New[string, int](0)
+	assert.Nil(t, c)
+
+	c.Set("a", 1, time.Minute)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	c.Delete("a")
+	assert.Equal(t, Stats{}, c.Stats())
+}
+
+// TestBypass tests that only contexts created with WithBypass bypass the cache.
+func TestBypass(t *testing.T) {
+	assert.False(t, Bypassed(context.TODO()))
+	assert.True(t, Bypassed(WithBypass(context.TODO())))
+}
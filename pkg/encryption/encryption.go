@@ -0,0 +1,154 @@
+// Encryption implements envelope encryption: data is encrypted with a fresh data key,
+// which is in turn encrypted with a master key held by a pluggable key provider.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// KeySize is the size of master and data keys (AES-256).
+const KeySize = 32
+
+var (
+	ErrorUnknownKey      = errors.New("unknown master key")
+	ErrorInvalidKey      = errors.New("invalid key")
+	ErrorInvalidKeyFile  = errors.New("invalid key file")
+	ErrorFailedToEncrypt = errors.New("failed to encrypt")
+	ErrorFailedToDecrypt = errors.New("failed to decrypt")
+)
+
+// KeyProvider generates data keys encrypted with master keys and decrypts them. Master keys
+// can be rotated by making a new key current while keeping the previous ones available
+// for decryption of data keys encrypted with them.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key in plaintext and encrypted with the current
+	// master key identified by keyID.
+	GenerateDataKey(ctx context.Context) (keyID string, plaintext []byte, encrypted []byte, err error)
+	// DecryptDataKey returns plaintext of data key encrypted with master key keyID.
+	DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error)
+}
+
+// LocalKeyProvider holds master keys in memory, it is meant for tests and local runs.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// keyFile is the format of key files of LocalKeyProvider: ID of the current master key
+// and base64 encoded master keys by ID.
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// NewLocalKeyProvider returns key provider encrypting data keys with master key current
+// and decrypting them with any of keys.
+func NewLocalKeyProvider(current string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: %v", ErrorUnknownKey, current)
+	}
+	for id, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("%w: %v is not %d bytes long", ErrorInvalidKey, id, KeySize)
+		}
+	}
+	return &LocalKeyProvider{current: current, keys: keys}, nil
+}
+
+// LoadLocalKeyProvider returns key provider with master keys read from JSON key file, e.g.
+// {"current": "2024-07", "keys": {"2024-01": "<base64>", "2024-07": "<base64>"}}.
+func LoadLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidKeyFile, err)
+	}
+
+	var f keyFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidKeyFile, err)
+	}
+	keys := map[string][]byte{}
+	for id, encoded := range f.Keys {
+		keys[id], err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %v: %w", ErrorInvalidKeyFile, id, err)
+		}
+	}
+
+	provider, err := NewLocalKeyProvider(f.Current, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidKeyFile, err)
+	}
+	return provider, nil
+}
+
+func (p *LocalKeyProvider) GenerateDataKey(_ context.Context) (string, []byte, []byte, error) {
+	plaintext := make([]byte, KeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %w", ErrorFailedToEncrypt, err)
+	}
+
+	encrypted, err := Seal(p.keys[p.current], plaintext, []byte(p.current))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return p.current, plaintext, encrypted, nil
+}
+
+func (p *LocalKeyProvider) DecryptDataKey(_ context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrorUnknownKey, keyID)
+	}
+	return Open(key, encrypted, []byte(keyID))
+}
+
+// Seal encrypts and authenticates plaintext and additional data with AES-GCM. The random
+// nonce is prepended to the returned ciphertext.
+func Seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToEncrypt, err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToEncrypt, err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts ciphertext returned by Seal and authenticates it with additional data.
+func Open(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToDecrypt, err)
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrorFailedToDecrypt)
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// newGCM returns AES-GCM cipher with key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
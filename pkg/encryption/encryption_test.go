@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSealOpen tests the Seal and Open functions to ensure ciphertexts decrypt to their
+// plaintexts only with the same key and additional data.
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	otherKey := bytes.Repeat([]byte{2}, KeySize)
+
+	ciphertext, err := Seal(key, []byte("Bartlomiej"), []byte("USER#a\x00firstName"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, string(ciphertext), "Bartlomiej")
+
+	plaintext, err := Open(key, ciphertext, []byte("USER#a\x00firstName"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Bartlomiej", string(plaintext))
+	}
+	_, err = Open(key, ciphertext, []byte("USER#a\x00lastName"))
+	assert.ErrorIs(t, err, ErrorFailedToDecrypt)
+	_, err = Open(otherKey, ciphertext, []byte("USER#a\x00firstName"))
+	assert.ErrorIs(t, err, ErrorFailedToDecrypt)
+	_, err = Open(key, ciphertext[:4], nil)
+	assert.ErrorIs(t, err, ErrorFailedToDecrypt)
+}
+
+// TestLocalKeyProvider tests the LocalKeyProvider to ensure data keys are encrypted with
+// the current master key and stay decryptable after the master key is rotated.
+func TestLocalKeyProvider(t *testing.T) {
+	ctx := context.TODO()
+	keys := map[string][]byte{
+		"old": bytes.Repeat([]byte{1}, KeySize),
+		"new": bytes.Repeat([]byte{2}, KeySize),
+	}
+
+	oldProvider, err := NewLocalKeyProvider("old", map[string][]byte{"old": keys["old"]})
+	if !assert.NoError(t, err) {
+		return
+	}
+	keyID, dataKey, encrypted, err := oldProvider.GenerateDataKey(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "old", keyID)
+	assert.Len(t, dataKey, KeySize)
+
+	// Rotated provider generates keys with the new master key and decrypts the old ones.
+	rotated, err := NewLocalKeyProvider("new", keys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	decrypted, err := rotated.DecryptDataKey(ctx, keyID, encrypted)
+	if assert.NoError(t, err) {
+		assert.Equal(t, dataKey, decrypted)
+	}
+	keyID, _, _, err = rotated.GenerateDataKey(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "new", keyID)
+	}
+
+	// Retired master key.
+	retired, err := NewLocalKeyProvider("new", map[string][]byte{"new": keys["new"]})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = retired.DecryptDataKey(ctx, "old", encrypted)
+	assert.ErrorIs(t, err, ErrorUnknownKey)
+
+	// Invalid keys.
+	_, err = NewLocalKeyProvider("missing", keys)
+	assert.ErrorIs(t, err, ErrorUnknownKey)
+	_, err = NewLocalKeyProvider("short", map[string][]byte{"short": []byte("short")})
+	assert.ErrorIs(t, err, ErrorInvalidKey)
+}
+
+// TestLoadLocalKeyProvider tests the LoadLocalKeyProvider function to ensure key files are
+// parsed and invalid key files are rejected.
+func TestLoadLocalKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))
+
+	tests := []struct {
+		name          string
+		content       string
+		expectedError error
+	}{
+		{
+			name:          "Valid key file",
+			content:       fmt.Sprintf(`{"current":"k1","keys":{"k1":"%v"}}`, key),
+			expectedError: nil,
+		},
+		{
+			name:          "Invalid JSON",
+			content:       `{"current":`,
+			expectedError: ErrorInvalidKeyFile,
+		},
+		{
+			name:          "Invalid base64",
+			content:       `{"current":"k1","keys":{"k1":"!"}}`,
+			expectedError: ErrorInvalidKeyFile,
+		},
+		{
+			name:          "Unknown current key",
+			content:       fmt.Sprintf(`{"current":"k2","keys":{"k1":"%v"}}`, key),
+			expectedError: ErrorUnknownKey,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("keys%d.json", i))
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			_, err := LoadLocalKeyProvider(path)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Group implements functions for managing groups of users (e.g. teams or departments)
+// and their many-to-many membership.
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+	validator "github.com/go-playground/validator/v10"
+)
+
+var (
+	validate *validator.Validate
+
+	ErrorFailedToUnmarshalMap   = errors.New("failed to unmarshal map for item")
+	ErrorFailedToValidateGroup  = errors.New("failed to validate group")
+	ErrorGroupDoesNotExist      = errors.New("group does not exist")
+	ErrorMembershipDoesNotExist = errors.New("membership does not exist")
+)
+
+func init() {
+	// Create validator of Group struct.
+	validate = validator.New()
+}
+
+// FetchGroup fetches group based on its ID within the tenant carried by ctx.
+func FetchGroup(ctx context.Context, id string) (*models.Group, error) {
+	item, err := repository.Default().Get(ctx, keyOf(ctx, id))
+	if errors.Is(err, repository.ErrorItemNotFound) {
+		logging.PrintfContext(ctx, "%v: %v", ErrorGroupDoesNotExist, id)
+		return nil, fmt.Errorf("%w: %v", ErrorGroupDoesNotExist, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalGroup(ctx, item)
+}
+
+// FetchGroups fetches all groups of the tenant carried by ctx.
+func FetchGroups(ctx context.Context) ([]models.Group, error) {
+	items, err := repository.Default().Scan(ctx, repository.EntityGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []models.Group
+	for _, item := range items {
+		g, err := unmarshalGroup(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *g)
+	}
+	logging.PrintfContext(ctx, "groups: %v", len(groups))
+
+	return groups, nil
+}
+
+// CreateGroup creates or overwrites group within the tenant carried by ctx.
+func CreateGroup(ctx context.Context, group models.Group) error {
+	// Validate group struct if it has required ID field.
+	err := validate.Struct(group)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToValidateGroup, group, err)
+		return fmt.Errorf("%w: %w", ErrorFailedToValidateGroup, err)
+	}
+
+	item := repository.NewItem(ctx, keyOf(ctx, group.ID), repository.EntityGroup)
+	item["id"] = &types.AttributeValueMemberS{Value: group.ID}
+	item["name"] = &types.AttributeValueMemberS{Value: group.Name}
+	item["description"] = &types.AttributeValueMemberS{Value: group.Description}
+	logging.PrintfContext(ctx, "CreateGroup key: %v", keyOf(ctx, group.ID))
+
+	return repository.Default().Put(ctx, item)
+}
+
+// UpdateGroup overwrites existing group within the tenant carried by ctx.
+func UpdateGroup(ctx context.Context, group models.Group) error {
+	err := validate.Struct(group)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToValidateGroup, group, err)
+		return fmt.Errorf("%w: %w", ErrorFailedToValidateGroup, err)
+	}
+
+	_, err = FetchGroup(ctx, group.ID)
+	if err != nil {
+		return err // Bypassing error from the FetchGroup function to the caller to build response.
+	}
+
+	return CreateGroup(ctx, group)
+}
+
+// DeleteGroup deletes group together with memberships of its users.
+func DeleteGroup(ctx context.Context, id string) (*models.Group, error) {
+	g, err := FetchGroup(ctx, id)
+	if err != nil {
+		return nil, err // Bypassing error from the FetchGroup function to the caller to build response.
+	}
+
+	// Delete memberships of the group with their counterparts in users' partitions.
+	err = repository.DeleteChildren(ctx, repository.Default(), keyOf(ctx, id).PK)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = repository.Default().Delete(ctx, keyOf(ctx, id))
+	if errors.Is(err, repository.ErrorItemNotFound) {
+		return nil, fmt.Errorf("%w: %v", ErrorGroupDoesNotExist, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// AddMember adds existing user to existing group. Membership is stored twice - in the
+// partition of the group and in the partition of the user - so both sides can be listed
+// with a single query.
+func AddMember(ctx context.Context, membership models.Membership) error {
+	err := validate.Struct(membership)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToValidateGroup, membership, err)
+		return fmt.Errorf("%w: %w", ErrorFailedToValidateGroup, err)
+	}
+
+	// Check both sides of the membership exist.
+	_, err = FetchGroup(ctx, membership.GroupID)
+	if err != nil {
+		return err
+	}
+	_, err = user.FetchUser(ctx, membership.Email)
+	if err != nil {
+		return err
+	}
+
+	memberKey, userGroupKey := membershipKeys(ctx, membership)
+	member := membershipItem(ctx, memberKey, membership)
+	repository.Link(member, userGroupKey)
+	userGroup := membershipItem(ctx, userGroupKey, membership)
+	repository.Link(userGroup, memberKey)
+
+	for _, item := range []repository.Item{member, userGroup} {
+		if err := repository.Default().Put(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveMember removes user from group.
+func RemoveMember(ctx context.Context, membership models.Membership) error {
+	memberKey, userGroupKey := membershipKeys(ctx, membership)
+
+	_, err := repository.Default().Delete(ctx, memberKey)
+	if errors.Is(err, repository.ErrorItemNotFound) {
+		logging.PrintfContext(ctx, "%v: %v", ErrorMembershipDoesNotExist, membership)
+		return fmt.Errorf("%w: %v", ErrorMembershipDoesNotExist, membership)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.Default().Delete(ctx, userGroupKey)
+	if err != nil && !errors.Is(err, repository.ErrorItemNotFound) {
+		return err
+	}
+	return nil
+}
+
+// FetchMembers fetches users who are members of group.
+func FetchMembers(ctx context.Context, id string) ([]models.User, error) {
+	_, err := FetchGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := repository.Default().Query(ctx, keyOf(ctx, id).PK, repository.SortKeyPrefix(repository.EntityUser))
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	for _, item := range items {
+		m, err := unmarshalMembership(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		u, err := user.FetchUser(ctx, m.Email)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, nil
+}
+
+// FetchUserGroups fetches groups the user is a member of.
+func FetchUserGroups(ctx context.Context, email string) ([]models.Group, error) {
+	_, err := user.FetchUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := repository.PartitionKey(ctx, repository.EntityUser, email)
+	items, err := repository.Default().Query(ctx, pk, repository.SortKeyPrefix(repository.EntityGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []models.Group
+	for _, item := range items {
+		m, err := unmarshalMembership(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		g, err := FetchGroup(ctx, m.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *g)
+	}
+	return groups, nil
+}
+
+// keyOf returns key of the root item of a group.
+func keyOf(ctx context.Context, id string) repository.Key {
+	return repository.EntityKey(ctx, repository.EntityGroup, id)
+}
+
+// membershipKeys returns keys of membership items in the partitions of the group and the user.
+func membershipKeys(ctx context.Context, m models.Membership) (repository.Key, repository.Key) {
+	memberKey := repository.ChildKey(ctx, repository.EntityGroup, m.GroupID, repository.EntityUser, m.Email)
+	userGroupKey := repository.ChildKey(ctx, repository.EntityUser, m.Email, repository.EntityGroup, m.GroupID)
+	return memberKey, userGroupKey
+}
+
+// membershipItem returns membership item with key.
+func membershipItem(ctx context.Context, key repository.Key, m models.Membership) repository.Item {
+	item := repository.NewItem(ctx, key, repository.EntityMembership)
+	item["groupId"] = &types.AttributeValueMemberS{Value: m.GroupID}
+	item["email"] = &types.AttributeValueMemberS{Value: m.Email}
+	return item
+}
+
+// unmarshalGroup extracts group from DynamoDB item.
+func unmarshalGroup(ctx context.Context, item repository.Item) (*models.Group, error) {
+	var g models.Group
+	err := attributevalue.UnmarshalMap(item, &g)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToUnmarshalMap, repository.KeyOf(item), err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToUnmarshalMap, err)
+	}
+	return &g, nil
+}
+
+// unmarshalMembership extracts membership from DynamoDB item.
+func unmarshalMembership(ctx context.Context, item repository.Item) (*models.Membership, error) {
+	var m models.Membership
+	err := attributevalue.UnmarshalMap(item, &m)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToUnmarshalMap, repository.KeyOf(item), err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToUnmarshalMap, err)
+	}
+	return &m, nil
+}
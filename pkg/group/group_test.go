@@ -0,0 +1,210 @@
+package group
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+	"github.com/stretchr/testify/assert"
+)
+
+// setup replaces the default repository with in-memory one holding two users and two groups.
+func setup(t *testing.T) context.Context {
+	t.Helper()
+	previous := repository.SetDefault(repository.NewMemory())
+	t.Cleanup(func() { repository.SetDefault(previous) })
+
+	ctx := context.TODO()
+	for _, u := range []models.User{testutil.ValidUser1, testutil.ValidUser2} {
+		assert.NoError(t, user.CreateUser(ctx, u))
+	}
+	for _, g := range []models.Group{testutil.ValidGroup1, testutil.ValidGroup2} {
+		assert.NoError(t, CreateGroup(ctx, g))
+	}
+	return ctx
+}
+
+// TestFetchGroup tests the FetchGroup function to ensure it correctly retrieves a group by ID.
+// It verifies that the function returns the expected group for a valid ID,
+// and the appropriate error for a non-existing group.
+func TestFetchGroup(t *testing.T) {
+	ctx := setup(t)
+
+	tests := []struct {
+		name          string
+		id            string
+		expectedGroup *models.Group
+		expectedError error
+	}{
+		{
+			name:          "Valid group",
+			id:            testutil.ValidGroup1.ID,
+			expectedGroup: &testutil.ValidGroup1,
+			expectedError: nil,
+		},
+		{
+			name:          "Invalid group",
+			id:            testutil.InvalidGroup1.ID,
+			expectedGroup: nil,
+			expectedError: ErrorGroupDoesNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := FetchGroup(ctx, tt.id)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				if assert.NoError(t, err) {
+					assert.Equal(t, *tt.expectedGroup, *g)
+				}
+			}
+		})
+	}
+}
+
+// TestFetchGroups tests the FetchGroups function to ensure it retrieves all groups
+// and nothing but groups.
+func TestFetchGroups(t *testing.T) {
+	ctx := setup(t)
+	assert.NoError(t, AddMember(ctx, models.Membership{GroupID: testutil.ValidGroup1.ID, Email: testutil.ValidUser1.Email}))
+
+	groups, err := FetchGroups(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.Group{testutil.ValidGroup1, testutil.ValidGroup2}, groups)
+	}
+}
+
+// TestUpdateGroup tests the UpdateGroup function to ensure it updates existing groups
+// and returns the appropriate error for non-existing groups or invalid data.
+func TestUpdateGroup(t *testing.T) {
+	ctx := setup(t)
+
+	updated := testutil.ValidGroup1
+	updated.Description = "Updated"
+
+	tests := []struct {
+		name          string
+		group         models.Group
+		expectedError error
+	}{
+		{
+			name:          "Valid group",
+			group:         updated,
+			expectedError: nil,
+		},
+		{
+			name:          "Invalid group",
+			group:         testutil.InvalidGroup1,
+			expectedError: ErrorGroupDoesNotExist,
+		},
+		{
+			name:          "Empty group",
+			group:         models.Group{Name: "test"},
+			expectedError: ErrorFailedToValidateGroup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := UpdateGroup(ctx, tt.group)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				g, err := FetchGroup(ctx, tt.group.ID)
+				if assert.NoError(t, err) {
+					assert.Equal(t, tt.group, *g)
+				}
+			}
+		})
+	}
+}
+
+// TestMembership tests the AddMember, RemoveMember, FetchMembers and FetchUserGroups
+// functions to ensure membership is visible from both the group and the user side, and
+// that memberships of non-existing users, groups or memberships are rejected.
+func TestMembership(t *testing.T) {
+	ctx := setup(t)
+
+	engineering1 := models.Membership{GroupID: testutil.ValidGroup1.ID, Email: testutil.ValidUser1.Email}
+	engineering2 := models.Membership{GroupID: testutil.ValidGroup1.ID, Email: testutil.ValidUser2.Email}
+	sales1 := models.Membership{GroupID: testutil.ValidGroup2.ID, Email: testutil.ValidUser1.Email}
+	for _, m := range []models.Membership{engineering1, engineering2, sales1} {
+		assert.NoError(t, AddMember(ctx, m))
+	}
+
+	// Invalid memberships.
+	err := AddMember(ctx, models.Membership{GroupID: testutil.InvalidGroup1.ID, Email: testutil.ValidUser1.Email})
+	assert.ErrorIs(t, err, ErrorGroupDoesNotExist)
+	err = AddMember(ctx, models.Membership{GroupID: testutil.ValidGroup1.ID, Email: testutil.InvalidUser1.Email})
+	assert.ErrorIs(t, err, user.ErrorUserDoesNotExist)
+	err = AddMember(ctx, models.Membership{GroupID: testutil.ValidGroup1.ID})
+	assert.ErrorIs(t, err, ErrorFailedToValidateGroup)
+	err = RemoveMember(ctx, models.Membership{GroupID: testutil.ValidGroup2.ID, Email: testutil.ValidUser2.Email})
+	assert.ErrorIs(t, err, ErrorMembershipDoesNotExist)
+
+	// Both sides of membership.
+	members, err := FetchMembers(ctx, testutil.ValidGroup1.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.User{testutil.ValidUser1, testutil.ValidUser2}, members)
+	}
+	groups, err := FetchUserGroups(ctx, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.Group{testutil.ValidGroup1, testutil.ValidGroup2}, groups)
+	}
+
+	// Removal is visible from both sides.
+	assert.NoError(t, RemoveMember(ctx, engineering1))
+	members, err = FetchMembers(ctx, testutil.ValidGroup1.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.User{testutil.ValidUser2}, members)
+	}
+	groups, err = FetchUserGroups(ctx, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.Group{testutil.ValidGroup2}, groups)
+	}
+}
+
+// TestDeleteCascade tests that deleting a user with user.DeleteUser removes the user's
+// memberships from all groups, and that deleting a group removes it from users' groups.
+func TestDeleteCascade(t *testing.T) {
+	ctx := setup(t)
+
+	for _, m := range []models.Membership{
+		{GroupID: testutil.ValidGroup1.ID, Email: testutil.ValidUser1.Email},
+		{GroupID: testutil.ValidGroup1.ID, Email: testutil.ValidUser2.Email},
+		{GroupID: testutil.ValidGroup2.ID, Email: testutil.ValidUser1.Email},
+		{GroupID: testutil.ValidGroup2.ID, Email: testutil.ValidUser2.Email},
+	} {
+		assert.NoError(t, AddMember(ctx, m))
+	}
+
+	// Deleting user removes it from groups.
+	_, err := user.DeleteUser(ctx, testutil.ValidUser1.Email)
+	assert.NoError(t, err)
+	for _, g := range []models.Group{testutil.ValidGroup1, testutil.ValidGroup2} {
+		members, err := FetchMembers(ctx, g.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []models.User{testutil.ValidUser2}, members)
+		}
+	}
+
+	// Deleting group removes it from users' groups.
+	g, err := DeleteGroup(ctx, testutil.ValidGroup1.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testutil.ValidGroup1, *g)
+	}
+	groups, err := FetchUserGroups(ctx, testutil.ValidUser2.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.Group{testutil.ValidGroup2}, groups)
+	}
+	_, err = DeleteGroup(ctx, testutil.ValidGroup1.ID)
+	assert.ErrorIs(t, err, ErrorGroupDoesNotExist)
+}
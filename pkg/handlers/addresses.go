@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/address"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+)
+
+var (
+	ErrorNoEmailPathParameter = errors.New("no email path parameter")
+	ErrorNoIDPathParameter    = errors.New("no id path parameter")
+)
+
+func init() {
+	RegisterError(address.ErrorAddressDoesNotExist, http.StatusNotFound, ErrorNotFound)
+	RegisterError(address.ErrorFailedToValidateAddress, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(address.ErrorDefaultAddressRequired, http.StatusBadRequest, ErrorBadRequest)
+}
+
+// unmarshalAddress unmarshals address from body of request strictly.
+func unmarshalAddress(ctx context.Context, request events.APIGatewayProxyRequest) (*models.Address, error) {
+	var a models.Address
+	err := decodeBody(request, &a)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorInvalidJSON, err)
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidJSON, err)
+	}
+	logging.PrintfContext(ctx, "Address: %v", a)
+
+	return &a, nil
+}
+
+// GetAddress gets address of user from DynamoDB and responds.
+func GetAddress(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract user's email and address' ID from request.
+	email, id := request.PathParameters["email"], request.PathParameters["id"]
+	if email == "" {
+		return errorResponse(ctx, ErrorNoEmailPathParameter)
+	}
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDPathParameter)
+	}
+
+	// Fetch address.
+	a, err := address.FetchAddress(ctx, email, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, a)
+}
+
+// GetAddresses gets all addresses of user from DynamoDB and responds.
+func GetAddresses(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract user's email from request.
+	email := request.PathParameters["email"]
+	if email == "" {
+		return errorResponse(ctx, ErrorNoEmailPathParameter)
+	}
+
+	// Fetch addresses.
+	addresses, err := address.FetchAddresses(ctx, email)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, addresses)
+}
+
+// CreateAddress creates address of user in DynamoDB table and responds.
+func CreateAddress(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract user's email from request.
+	email := request.PathParameters["email"]
+	if email == "" {
+		return errorResponse(ctx, ErrorNoEmailPathParameter)
+	}
+
+	// Unmarshal received address JSON data.
+	a, err := unmarshalAddress(ctx, request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Create address.
+	a, err = address.CreateAddress(ctx, email, *a)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusCreated, a)
+}
+
+// UpdateAddress updates address of user in DynamoDB table and responds. ID of the address
+// is taken from the path.
+func UpdateAddress(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract user's email and address' ID from request.
+	email, id := request.PathParameters["email"], request.PathParameters["id"]
+	if email == "" {
+		return errorResponse(ctx, ErrorNoEmailPathParameter)
+	}
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDPathParameter)
+	}
+
+	// Unmarshal received address JSON data.
+	a, err := unmarshalAddress(ctx, request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	a.ID = id
+
+	// Update address.
+	a, err = address.UpdateAddress(ctx, email, *a)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, a)
+}
+
+// DeleteAddress deletes address of user from DynamoDB table and responds.
+func DeleteAddress(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract user's email and address' ID from request.
+	email, id := request.PathParameters["email"], request.PathParameters["id"]
+	if email == "" {
+		return errorResponse(ctx, ErrorNoEmailPathParameter)
+	}
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDPathParameter)
+	}
+
+	// Delete address.
+	a, err := address.DeleteAddress(ctx, email, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, a)
+}
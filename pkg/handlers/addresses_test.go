@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAddresses tests the address handlers in sequence against in-memory repository to
+// ensure addresses of a user are created, fetched, updated and deleted. It verifies the
+// status codes and bodies of responses, including missing path parameters, invalid
+// addresses and non-existing users and addresses.
+func TestAddresses(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	actual, _ := CreateUser(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidUser})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
+	userPath := map[string]string{"email": testutil.ValidUser1.Email}
+	addressPath := map[string]string{"email": testutil.ValidUser1.Email, "id": testutil.ValidAddress1.ID}
+	updated := fmt.Sprintf(
+		`{"id":"%v","type":"billing","default":true,"line1":"2 Main Street","city":"Springfield","region":"IL","postalCode":"62701","country":"US"}`,
+		testutil.ValidAddress1.ID)
+
+	tests := []struct {
+		name     string
+		handler  func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		request  events.APIGatewayProxyRequest
+		expected events.APIGatewayProxyResponse
+	}{
+		{
+			name:     "Create address",
+			handler:  CreateAddress,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "POST", PathParameters: userPath, Body: testutil.ValidAddress},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusCreated, Body: testutil.ValidAddress},
+		},
+		{
+			name:    "Create address without email",
+			handler: CreateAddress,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidAddress},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       problemJSON(t, http.StatusBadRequest, "no-email-path-parameter", ""),
+			},
+		},
+		{
+			name:    "Create invalid address",
+			handler: CreateAddress,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				PathParameters: userPath,
+				Body:           `{"id":"home","type":"home","line1":"1","city":"Springfield","postalCode":"1","country":"US"}`,
+			},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body: problemJSON(t, http.StatusBadRequest, "invalid-address", "failed to validate address: "+
+					"Key: 'Address.PostalCode' Error:Field validation for 'PostalCode' failed on the 'postcode_US' tag\n"+
+					"Key: 'Address.Region' Error:Field validation for 'Region' failed on the 'required_for_US' tag"),
+			},
+		},
+		{
+			name:    "Create address of non-existing user",
+			handler: CreateAddress,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"email": testutil.InvalidUser1.Email},
+				Body:           testutil.ValidAddress,
+			},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       problemJSON(t, http.StatusNotFound, "user-not-found", "user does not exist: [email:e090d6e2]"),
+			},
+		},
+		{
+			name:     "Get address",
+			handler:  GetAddress,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", PathParameters: addressPath},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: testutil.ValidAddress},
+		},
+		{
+			name:     "Get addresses",
+			handler:  GetAddresses,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", PathParameters: userPath},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + testutil.ValidAddress + "]"},
+		},
+		{
+			name:    "Update address",
+			handler: UpdateAddress,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "PUT",
+				PathParameters: addressPath,
+				Body:           `{"type":"billing","default":true,"line1":"2 Main Street","city":"Springfield","region":"IL","postalCode":"62701","country":"US"}`,
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: updated},
+		},
+		{
+			name:     "Delete address",
+			handler:  DeleteAddress,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "DELETE", PathParameters: addressPath},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: updated},
+		},
+		{
+			name:    "Get deleted address",
+			handler: GetAddress,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "GET", PathParameters: addressPath},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       problemJSON(t, http.StatusNotFound, "address-not-found", "address does not exist: [email:62e650e5], home"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, _ := tt.handler(context.TODO(), tt.request)
+			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
+			assert.JSONEq(t, tt.expected.Body, actual.Body)
+		})
+	}
+}
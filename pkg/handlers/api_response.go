@@ -2,37 +2,46 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"math"
+	"net/http"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
 )
 
 var (
 	ErrorFailedToMarshalJSON = errors.New("failed to marshal JSON")
 )
 
+// successfulStatuses are status codes of responses with body as is, others carry problem
+// details.
+var successfulStatuses = []int{200, 201}
+
 // buildResponseBody returns body of the API response based on the status code.
-func buildResponseBody(status int, body interface{}) (int, string) {
-	successfulStatuses := []int{200, 201}
-	// If the status is not successful then add error message to response body.
+func buildResponseBody(ctx context.Context, status int, body interface{}) (int, string) {
+	// If the status is not successful then build problem details of the error.
 	if !slices.Contains(successfulStatuses, status) {
-		// If exists, convert error to string.
-		var errorMessage string
-		if err, ok := body.(error); ok {
-			errorMessage = err.Error()
-		} else {
-			errorMessage = fmt.Sprintf("%v", body)
+		var problem ProblemDetails
+		switch b := body.(type) {
+		case ProblemDetails:
+			problem = b
+		case error:
+			problem = newProblemDetails(status, b)
+		default:
+			problem = ProblemDetails{Type: BlankProblemType, Title: http.StatusText(status), Status: status, Detail: fmt.Sprintf("%v", b)}
 		}
 
-		// Build body with error message.
-		body := map[string]interface{}{"error": errorMessage}
-		responseBody, err := json.Marshal(body)
+		responseBody, err := json.Marshal(problem)
 		if err != nil {
-			log.Printf("%v: %v", ErrorFailedToMarshalJSON, err)
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToMarshalJSON, err)
 			return 500, ""
 		}
 		return status, string(responseBody)
@@ -40,7 +49,7 @@ func buildResponseBody(status int, body interface{}) (int, string) {
 	} else {
 		responseBody, err := json.Marshal(body)
 		if err != nil {
-			log.Printf("%v: %v", ErrorFailedToMarshalJSON, err)
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToMarshalJSON, err)
 			return 500, ""
 		}
 		return status, string(responseBody)
@@ -48,14 +57,44 @@ func buildResponseBody(status int, body interface{}) (int, string) {
 }
 
 // buildAPIResponse builds API response.
-func buildAPIResponse(status int, body interface{}) (*events.APIGatewayProxyResponse, error) {
+func buildAPIResponse(ctx context.Context, status int, body interface{}) (*events.APIGatewayProxyResponse, error) {
 	// Build response body.
-	log.Printf("buildAPIResponse status: %v", status)
+	logging.PrintfContext(ctx, "buildAPIResponse status: %v", status)
 	responseBody := &events.APIGatewayProxyResponse{
 		Headers:    map[string]string{"Content-Type": "application/json"},
 		StatusCode: status,
 	}
 
-	responseBody.StatusCode, responseBody.Body = buildResponseBody(status, body)
+	responseBody.StatusCode, responseBody.Body = buildResponseBody(ctx, status, body)
+	if !slices.Contains(successfulStatuses, responseBody.StatusCode) {
+		responseBody.Headers["Content-Type"] = MediaTypeProblemJSON
+	}
+
+	// Advise clients when to retry if DynamoDB was unavailable.
+	if responseBody.StatusCode == http.StatusServiceUnavailable {
+		bodyErr, _ := body.(error)
+		setRetryAfter(responseBody.Headers, bodyErr)
+	}
 	return responseBody, nil
 }
+
+// setRetryAfter sets Retry-After header advising clients when to retry requests which failed
+// with err, in whole seconds.
+func setRetryAfter(headers map[string]string, err error) {
+	retryAfter := int(math.Ceil(repository.RetryAfter(err).Seconds()))
+	headers["Retry-After"] = strconv.Itoa(retryAfter)
+}
+
+// addVary adds request header the response varies by to Vary header of the response.
+func addVary(headers map[string]string, name string) {
+	vary := headers[VaryHeader]
+	for _, v := range strings.Split(vary, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), name) {
+			return
+		}
+	}
+	if vary != "" {
+		vary += ", "
+	}
+	headers[VaryHeader] = vary + name
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+// WWWAuthenticateHeader tells clients of unauthorized requests how to authenticate.
+const WWWAuthenticateHeader = "WWW-Authenticate"
+
+var (
+	ErrorUnauthorized = errors.New("unauthorized")
+
+	// authRequired makes WithAuth reject requests without principal. It is disabled with
+	// AUTH_REQUIRED=false, e.g. to serve HTTP requests locally without authorizer.
+	authRequired = authRequiredFromEnv()
+)
+
+func init() {
+	RegisterError(ErrorUnauthorized, http.StatusUnauthorized, ErrorUnauthorized)
+}
+
+// SetAuthRequired replaces whether WithAuth rejects requests without principal.
+func SetAuthRequired(required bool) {
+	authRequired = required
+}
+
+// authRequiredFromEnv returns AUTH_REQUIRED environment variable falling back to true.
+func authRequiredFromEnv() bool {
+	if v, err := strconv.ParseBool(os.Getenv("AUTH_REQUIRED")); err == nil {
+		return v
+	}
+	return true
+}
+
+// principal returns the caller authenticated by API Gateway: subject of Cognito or JWT
+// claims, principal of Lambda authorizer or ARN of IAM caller. It is "" if there is none.
+func principal(request events.APIGatewayProxyRequest) string {
+	authorizer := request.RequestContext.Authorizer
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return sub
+		}
+	}
+	if id, ok := authorizer["principalId"].(string); ok && id != "" {
+		return id
+	}
+	return request.RequestContext.Identity.UserArn
+}
+
+// WithAuth wraps handler, so requests without principal authenticated by API Gateway
+// authorizer are rejected with 401 status code before reaching handler.
+func WithAuth(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		if authRequired && principal(request) == "" {
+			logging.PrintfContext(ctx, "%v: %v %v", ErrorUnauthorized, request.HTTPMethod, request.Path)
+			response, err := errorResponse(ctx, ErrorUnauthorized)
+			response.Headers[WWWAuthenticateHeader] = "Bearer"
+			return response, err
+		}
+		return handler(ctx, request)
+	}
+}
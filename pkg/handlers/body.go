@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DefaultMaxBodySize is the default size limit of request bodies in bytes.
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	ErrorUnsupportedMediaType = errors.New("unsupported media type")
+	ErrorBodyTooLarge         = errors.New("request body too large")
+)
+
+func init() {
+	RegisterError(ErrorUnsupportedMediaType, http.StatusUnsupportedMediaType, ErrorUnsupportedMediaType)
+	RegisterError(ErrorBodyTooLarge, http.StatusRequestEntityTooLarge, ErrorBodyTooLarge)
+}
+
+var maxBodySize = maxBodySizeFromEnv()
+
+// SetMaxBodySize replaces the size limit of request bodies in bytes.
+func SetMaxBodySize(size int) {
+	maxBodySize = size
+}
+
+// MaxBodySize returns the size limit of request bodies in bytes.
+func MaxBodySize() int {
+	return maxBodySize
+}
+
+// maxBodySizeFromEnv returns size limit of request bodies of MAX_BODY_SIZE environment
+// variable falling back to the default.
+func maxBodySizeFromEnv() int {
+	if v, err := strconv.Atoi(os.Getenv("MAX_BODY_SIZE")); err == nil && v > 0 {
+		return v
+	}
+	return DefaultMaxBodySize
+}
+
+// Problem is a problem of request body at JSON pointer (RFC 6901), "" for the whole body.
+type Problem struct {
+	Pointer string `json:"pointer"`
+	Reason  string `json:"reason"`
+}
+
+// ProblemsError is error with problems of request body clients can fix.
+type ProblemsError struct {
+	Err      error
+	Problems []Problem
+}
+
+func (e *ProblemsError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = fmt.Sprintf("%q %v", p.Pointer, p.Reason)
+	}
+	return fmt.Sprintf("%v: %v", e.Err, strings.Join(problems, "; "))
+}
+
+func (e *ProblemsError) Unwrap() error {
+	return e.Err
+}
+
+// invalidJSON returns invalid JSON error with a problem of the whole body.
+func invalidJSON(reason string) error {
+	return &ProblemsError{Err: ErrorInvalidJSON, Problems: []Problem{{Pointer: "", Reason: reason}}}
+}
+
+// isJSON reports whether content type is "application/json" or has "+json" suffix.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == MediaTypeJSON || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// decodedLen returns length of base64 encoded s once decoded, without decoding it.
+func decodedLen(s string) int {
+	padding := len(s) - len(strings.TrimRight(s, "="))
+	return max(base64.StdEncoding.DecodedLen(len(s))-min(padding, 2), 0)
+}
+
+// decodeBody decodes JSON body of request into v strictly: the body must be JSON (bodies
+// without Content-Type header are taken for JSON), base64 decoded if encoded, within the size
+// limit, and without unknown fields or values of wrong types. Problems of the body are
+// returned in ProblemsError.
+func decodeBody(request events.APIGatewayProxyRequest, v any) error {
+	if contentType := headerValue(request.Headers, ContentTypeHeader); contentType != "" && !isJSON(contentType) {
+		return fmt.Errorf("%w: %v", ErrorUnsupportedMediaType, contentType)
+	}
+
+	// Reject bodies above the size limit before decoding them.
+	size := len(request.Body)
+	if request.IsBase64Encoded {
+		size = decodedLen(request.Body)
+	}
+	if size > maxBodySize {
+		return fmt.Errorf("%w: %v bytes exceed %v bytes", ErrorBodyTooLarge, size, maxBodySize)
+	}
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(request.Body); err != nil {
+			return invalidJSON("body is not valid base64")
+		}
+	}
+
+	// Report syntax errors before problems of the values.
+	if len(bytes.TrimSpace(body)) == 0 {
+		return invalidJSON("body is empty")
+	}
+	var raw json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return invalidJSON(fmt.Sprintf("%v at offset %v", syntaxErr, syntaxErr.Offset))
+		}
+		return invalidJSON(err.Error())
+	}
+
+	// Collect all problems of the values at once.
+	decoded, err := decodeJSON(string(body))
+	if err != nil {
+		return invalidJSON(err.Error())
+	}
+	var problems []Problem
+	checkValue(&problems, "", decoded, reflect.TypeOf(v).Elem())
+	if len(problems) > 0 {
+		return &ProblemsError{Err: ErrorInvalidJSON, Problems: problems}
+	}
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return invalidJSON(err.Error())
+	}
+	return nil
+}
+
+// jsonType returns JSON type of value decoded from JSON.
+func jsonType(v any) string {
+	switch v.(type) {
+	case Object:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// escapePointer escapes reference token of JSON pointer.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// checkValue appends problems of value decoded from JSON at pointer to be decoded into type t.
+func checkValue(problems *[]Problem, pointer string, v any, t reflect.Type) {
+	// Null leaves any value as is, types decoding themselves are left to them.
+	if v == nil || reflect.PointerTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+		return
+	}
+	mismatch := func(expected string) {
+		*problems = append(*problems, Problem{
+			Pointer: pointer,
+			Reason:  fmt.Sprintf("must be %v, got %v", expected, jsonType(v)),
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkValue(problems, pointer, v, t.Elem())
+	case reflect.Interface:
+	case reflect.Struct:
+		o, ok := v.(Object)
+		if !ok {
+			mismatch("object")
+			return
+		}
+		fields := jsonFields(t)
+		for _, m := range o {
+			field, ok := lookupField(fields, m.Name)
+			if !ok {
+				*problems = append(*problems, Problem{Pointer: pointer + "/" + escapePointer(m.Name), Reason: "unknown field"})
+				continue
+			}
+			checkValue(problems, pointer+"/"+escapePointer(m.Name), m.Value, field)
+		}
+	case reflect.Map:
+		o, ok := v.(Object)
+		if !ok {
+			mismatch("object")
+			return
+		}
+		for _, m := range o {
+			checkValue(problems, pointer+"/"+escapePointer(m.Name), m.Value, t.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		// Byte slices are base64 encoded strings.
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			if _, ok := v.(string); !ok {
+				mismatch("string")
+			}
+			return
+		}
+		a, ok := v.([]any)
+		if !ok {
+			mismatch("array")
+			return
+		}
+		for i, e := range a {
+			checkValue(problems, pointer+"/"+strconv.Itoa(i), e, t.Elem())
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			mismatch("string")
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			mismatch("boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(json.Number)
+		if !ok {
+			mismatch("integer")
+		} else if _, err := strconv.ParseInt(n.String(), 10, t.Bits()); err != nil {
+			*problems = append(*problems, Problem{Pointer: pointer, Reason: "must be integer within range"})
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.(json.Number)
+		if !ok {
+			mismatch("integer")
+		} else if _, err := strconv.ParseUint(n.String(), 10, t.Bits()); err != nil {
+			*problems = append(*problems, Problem{Pointer: pointer, Reason: "must be non-negative integer within range"})
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(json.Number); !ok {
+			mismatch("number")
+		}
+	}
+}
+
+// jsonFields returns types of fields of struct type by their JSON names, including fields
+// of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupField returns type of field of JSON name, matched case-insensitively like
+// encoding/json does if there is no exact match.
+func lookupField(fields map[string]reflect.Type, name string) (reflect.Type, bool) {
+	if t, ok := fields[name]; ok {
+		return t, true
+	}
+	for n, t := range fields {
+		if strings.EqualFold(n, name) {
+			return t, true
+		}
+	}
+	return nil, false
+}
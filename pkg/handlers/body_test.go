@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeBody tests the decodeBody function to ensure JSON bodies are decoded strictly,
+// listing every unknown field and value of wrong type with its JSON pointer, and rejecting
+// bodies of other media types, invalid base64 encoding or above the size limit.
+func TestDecodeBody(t *testing.T) {
+	previous := maxBodySize
+	defer SetMaxBodySize(previous)
+	SetMaxBodySize(200)
+
+	type document struct {
+		Name   string            `json:"name"`
+		Tags   []string          `json:"tags"`
+		Labels map[string]int    `json:"labels"`
+		Owner  *models.User      `json:"owner,omitempty"`
+		Extra  map[string]string `json:"-"`
+	}
+	user := `{"email":"a@b.com","age":37}`
+
+	tests := []struct {
+		name             string
+		request          events.APIGatewayProxyRequest
+		expected         document
+		expectedError    error
+		expectedProblems []Problem
+	}{
+		{
+			name: "Valid JSON",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{"content-type": "application/json; charset=utf-8"},
+				Body:    `{"name":"a","tags":["x"],"labels":{"a/b":1},"owner":` + user + `}`,
+			},
+			expected: document{Name: "a", Tags: []string{"x"}, Labels: map[string]int{"a/b": 1}, Owner: &models.User{Email: "a@b.com", Age: 37}},
+		},
+		{
+			name: "Suffix JSON media type and base64 encoding",
+			request: events.APIGatewayProxyRequest{
+				Headers:         map[string]string{"Content-Type": "application/merge-patch+json"},
+				Body:            base64.StdEncoding.EncodeToString([]byte(`{"name":"a","owner":null}`)),
+				IsBase64Encoded: true,
+			},
+			expected: document{Name: "a"},
+		},
+		{
+			name:          "Unknown fields and wrong types",
+			request:       events.APIGatewayProxyRequest{Body: `{"name":1,"nick":"a","tags":["x",2],"labels":{"a/b":"1","c~d":1.5},"owner":{"email":"a@b.com","age":"37","Extra":1}}`},
+			expectedError: ErrorInvalidJSON,
+			expectedProblems: []Problem{
+				{Pointer: "/name", Reason: "must be string, got number"},
+				{Pointer: "/nick", Reason: "unknown field"},
+				{Pointer: "/tags/1", Reason: "must be string, got number"},
+				{Pointer: "/labels/a~1b", Reason: "must be integer, got string"},
+				{Pointer: "/labels/c~0d", Reason: "must be integer within range"},
+				{Pointer: "/owner/age", Reason: "must be integer, got string"},
+				{Pointer: "/owner/Extra", Reason: "unknown field"},
+			},
+		},
+		{
+			name:             "Wrong type of body",
+			request:          events.APIGatewayProxyRequest{Body: `["a"]`},
+			expectedError:    ErrorInvalidJSON,
+			expectedProblems: []Problem{{Pointer: "", Reason: "must be object, got array"}},
+		},
+		{
+			name:             "Syntax error",
+			request:          events.APIGatewayProxyRequest{Body: `{"name":"a",}`},
+			expectedError:    ErrorInvalidJSON,
+			expectedProblems: []Problem{{Pointer: "", Reason: "invalid character '}' looking for beginning of object key string at offset 13"}},
+		},
+		{
+			name:             "Trailing data",
+			request:          events.APIGatewayProxyRequest{Body: `{"name":"a"} {}`},
+			expectedError:    ErrorInvalidJSON,
+			expectedProblems: []Problem{{Pointer: "", Reason: "invalid character '{' after top-level value at offset 14"}},
+		},
+		{
+			name:             "Empty body",
+			request:          events.APIGatewayProxyRequest{Body: " "},
+			expectedError:    ErrorInvalidJSON,
+			expectedProblems: []Problem{{Pointer: "", Reason: "body is empty"}},
+		},
+		{
+			name:             "Invalid base64 encoding",
+			request:          events.APIGatewayProxyRequest{Body: "{}", IsBase64Encoded: true},
+			expectedError:    ErrorInvalidJSON,
+			expectedProblems: []Problem{{Pointer: "", Reason: "body is not valid base64"}},
+		},
+		{
+			name: "Unsupported media type",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+				Body:    "name=a",
+			},
+			expectedError: ErrorUnsupportedMediaType,
+		},
+		{
+			name:          "Body too large",
+			request:       events.APIGatewayProxyRequest{Body: fmt.Sprintf(`{"name":"%v"}`, strings.Repeat("a", 200))},
+			expectedError: ErrorBodyTooLarge,
+		},
+		{
+			name: "Base64 encoded body too large",
+			request: events.APIGatewayProxyRequest{
+				Body:            base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"name":"%v"}`, strings.Repeat("a", 190)))),
+				IsBase64Encoded: true,
+			},
+			expectedError: ErrorBodyTooLarge,
+		},
+		{
+			name:          "Base64 encoded body too large rejected before decoding",
+			request:       events.APIGatewayProxyRequest{Body: strings.Repeat("!", 300), IsBase64Encoded: true},
+			expectedError: ErrorBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual document
+			err := decodeBody(tt.request, &actual)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, actual)
+				return
+			}
+			assert.ErrorIs(t, err, tt.expectedError)
+			if tt.expectedProblems != nil {
+				var problemsErr *ProblemsError
+				if assert.ErrorAs(t, err, &problemsErr) {
+					assert.Equal(t, tt.expectedProblems, problemsErr.Problems)
+				}
+			}
+		})
+	}
+}
+
+// TestProblemsResponse tests the CreateUser handler with invalid bodies to ensure 400 and 415
+// responses carry problem details, the 400 one with problems of the body.
+func TestProblemsResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		request        events.APIGatewayProxyRequest
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Invalid fields",
+			request:        events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: `{"email":"a@b.com","age":"37","role":"admin"}`},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"type":"` + ProblemTypeBaseURI + `invalid-json","title":"Invalid JSON body","status":400,"problems":[` +
+				`{"pointer":"/age","reason":"must be integer, got string"},` +
+				`{"pointer":"/role","reason":"unknown field"}]}`,
+		},
+		{
+			name: "Unsupported media type",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: "POST",
+				Headers:    map[string]string{"Content-Type": "text/plain"},
+				Body:       `{"email":"a@b.com"}`,
+			},
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   problemJSON(t, http.StatusUnsupportedMediaType, "unsupported-media-type", "invalid JSON: unsupported media type: text/plain"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := CreateUser(context.TODO(), tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, actual.StatusCode)
+			assert.JSONEq(t, tt.expectedBody, actual.Body)
+		})
+	}
+}
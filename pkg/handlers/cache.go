@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/cache"
+)
+
+// CacheControlHeader is the header allowing clients to bypass the cache of the container.
+const CacheControlHeader = "Cache-Control"
+
+// bypassesCache reports whether Cache-Control header of the request asks for a fresh
+// response with "no-cache", "no-store" or "max-age=0" directive.
+func bypassesCache(headers map[string]string) bool {
+	for name, value := range headers {
+		if !strings.EqualFold(name, CacheControlHeader) {
+			continue
+		}
+		for _, directive := range strings.Split(value, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-cache", "no-store", "max-age=0":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withCacheControl returns a copy of ctx bypassing the cache if the request asks for it.
+func withCacheControl(ctx context.Context, request events.APIGatewayProxyRequest) context.Context {
+	if bypassesCache(request.Headers) {
+		return cache.WithBypass(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBypassesCache tests the bypassesCache function to ensure requests asking for a fresh
+// response with Cache-Control directives bypass the cache, and other requests do not.
+func TestBypassesCache(t *testing.T) {
+	tests := []struct {
+		name           string
+		headers        map[string]string
+		expectedBypass bool
+	}{
+		{
+			name:           "No header",
+			headers:        nil,
+			expectedBypass: false,
+		},
+		{
+			name:           "No cache",
+			headers:        map[string]string{"Cache-Control": "no-cache"},
+			expectedBypass: true,
+		},
+		{
+			name:           "Lower case max age among directives",
+			headers:        map[string]string{"cache-control": "private, Max-Age=0"},
+			expectedBypass: true,
+		},
+		{
+			name:           "Positive max age",
+			headers:        map[string]string{"Cache-Control": "max-age=60"},
+			expectedBypass: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedBypass, bypassesCache(tt.headers))
+		})
+	}
+}
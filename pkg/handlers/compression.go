@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+const (
+	// AcceptEncodingHeader is the request header listing content codings the client accepts.
+	AcceptEncodingHeader = "Accept-Encoding"
+	// ContentEncodingHeader is the response header carrying content coding of the body.
+	ContentEncodingHeader = "Content-Encoding"
+)
+
+// Content codings of compressed response bodies.
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// CompressionPolicy configures compression of response bodies.
+type CompressionPolicy struct {
+	// MinSize is the size in bytes from which bodies are compressed. Compression is disabled
+	// if it is negative.
+	MinSize int
+}
+
+// DefaultCompressionPolicy compresses bodies of at least 1 KiB, smaller ones rarely get
+// smaller enough to be worth it.
+var DefaultCompressionPolicy = CompressionPolicy{MinSize: 1024}
+
+var compressionPolicy = compressionPolicyFromEnv()
+
+// SetCompressionPolicy replaces the compression policy.
+func SetCompressionPolicy(p CompressionPolicy) {
+	compressionPolicy = p
+}
+
+// compressionPolicyFromEnv builds compression policy from environment variables falling back
+// to defaults.
+func compressionPolicyFromEnv() CompressionPolicy {
+	p := DefaultCompressionPolicy
+	if v, err := strconv.Atoi(os.Getenv("COMPRESSION_MIN_SIZE")); err == nil {
+		p.MinSize = v
+	}
+	return p
+}
+
+// compressor is content coding of response bodies with its writer.
+type compressor struct {
+	coding    string
+	newWriter func(io.Writer) io.WriteCloser
+}
+
+// compressors are negotiated in order of preference.
+var compressors = []compressor{
+	{coding: EncodingBrotli, newWriter: func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	}},
+	{coding: EncodingGzip, newWriter: func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}},
+}
+
+// compressedMediaTypes are media types of bodies compressed already, besides images, audio
+// and video.
+var compressedMediaTypes = []string{
+	"application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+	"application/x-7z-compressed", "application/x-bzip2", "application/x-xz", "application/pdf",
+}
+
+// isCompressed reports whether body of media type is compressed already.
+func isCompressed(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	switch mainType {
+	case "image", "audio", "video":
+		// SVG is text.
+		return mediaType != "image/svg+xml"
+	}
+	for _, t := range compressedMediaTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateCompressor returns compressor of the highest quality accepted by Accept-Encoding
+// header, preferring the earlier one on ties. It returns false if none is accepted.
+func negotiateCompressor(ctx context.Context, acceptEncoding string) (compressor, bool) {
+	ranges := parseAccept(ctx, acceptEncoding)
+	best, bestQuality := compressor{}, 0.0
+	for _, c := range compressors {
+		// Exact coding takes precedence over "*".
+		specificity, q := -1, 0.0
+		for _, r := range ranges {
+			if r.mediaType == c.coding && specificity < 1 {
+				specificity, q = 1, r.quality
+			} else if r.mediaType == "*" && specificity < 0 {
+				specificity, q = 0, r.quality
+			}
+		}
+		if q > bestQuality {
+			best, bestQuality = c, q
+		}
+	}
+	return best, bestQuality > 0
+}
+
+// compress returns body compressed with compressor.
+func compress(c compressor, body []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := c.newWriter(&b)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// WithCompression wraps handler, so bodies of its responses of at least minimal size of the
+// compression policy are compressed with Brotli or gzip accepted by Accept-Encoding header,
+// and returned base64 encoded. Bodies encoded or compressed already are kept as they are.
+func WithCompression(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		p := compressionPolicy
+		response, err := handler(ctx, request)
+		if response == nil || p.MinSize < 0 || len(response.Body) < p.MinSize {
+			return response, err
+		}
+		if response.Headers == nil {
+			response.Headers = map[string]string{}
+		}
+		if headerValue(response.Headers, ContentEncodingHeader) != "" ||
+			isCompressed(headerValue(response.Headers, ContentTypeHeader)) {
+			return response, err
+		}
+		addVary(response.Headers, AcceptEncodingHeader)
+
+		c, ok := negotiateCompressor(ctx, headerValue(request.Headers, AcceptEncodingHeader))
+		if !ok {
+			return response, err
+		}
+
+		body := []byte(response.Body)
+		if response.IsBase64Encoded {
+			var decodeErr error
+			if body, decodeErr = base64.StdEncoding.DecodeString(response.Body); decodeErr != nil {
+				logging.PrintfContext(ctx, "failed to decode body: %v", decodeErr)
+				return response, err
+			}
+		}
+		compressed, compressErr := compress(c, body)
+		if compressErr != nil {
+			logging.PrintfContext(ctx, "failed to compress body with %v: %v", c.coding, compressErr)
+			return response, err
+		}
+		// Keep bodies compression doesn't make smaller.
+		if len(compressed) >= len(body) {
+			return response, err
+		}
+
+		logging.PrintfContext(ctx, "compressed body with %v: %v -> %v bytes", c.coding, len(body), len(compressed))
+		response.Headers[ContentEncodingHeader] = c.coding
+		response.Body = base64.StdEncoding.EncodeToString(compressed)
+		response.IsBase64Encoded = true
+		return response, err
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithCompression tests the WithCompression wrapper to ensure bodies above the threshold
+// are compressed with the preferred accepted coding and returned base64 encoded, while small,
+// binary and already compressed bodies, or requests accepting no coding, are kept as they are.
+func TestWithCompression(t *testing.T) {
+	previous := compressionPolicy
+	defer SetCompressionPolicy(previous)
+	SetCompressionPolicy(CompressionPolicy{MinSize: 100})
+
+	large := strings.Repeat(`{"email":"bartlomiej.jedrol@gmail.com","age":37},`, 10)
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		EncodingBrotli: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		EncodingGzip:   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	}
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		contentEncoding  string
+		body             string
+		expectedEncoding string
+		expectedVary     string
+	}{
+		{
+			name:             "Brotli preferred",
+			acceptEncoding:   "gzip, deflate, br",
+			contentType:      MediaTypeJSON,
+			body:             large,
+			expectedEncoding: EncodingBrotli,
+			expectedVary:     AcceptEncodingHeader,
+		},
+		{
+			name:             "Gzip of higher quality",
+			acceptEncoding:   "br;q=0.5, gzip",
+			contentType:      MediaTypeJSON,
+			body:             large,
+			expectedEncoding: EncodingGzip,
+			expectedVary:     AcceptEncodingHeader,
+		},
+		{
+			name:             "Any coding",
+			acceptEncoding:   "*",
+			contentType:      MediaTypeCSV,
+			body:             large,
+			expectedEncoding: EncodingBrotli,
+			expectedVary:     AcceptEncodingHeader,
+		},
+		{
+			name:           "No accepted coding",
+			acceptEncoding: "identity, br;q=0, *;q=0",
+			contentType:    MediaTypeJSON,
+			body:           large,
+			expectedVary:   AcceptEncodingHeader,
+		},
+		{
+			name:         "No Accept-Encoding header",
+			contentType:  MediaTypeJSON,
+			body:         large,
+			expectedVary: AcceptEncodingHeader,
+		},
+		{
+			name:           "Small body",
+			acceptEncoding: "gzip",
+			contentType:    MediaTypeJSON,
+			body:           `{"error":"not found"}`,
+		},
+		{
+			name:           "Compressed media type",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           large,
+		},
+		{
+			name:            "Encoded already",
+			acceptEncoding:  "gzip",
+			contentType:     MediaTypeJSON,
+			contentEncoding: "deflate",
+			body:            large,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := WithCompression(func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				headers := map[string]string{ContentTypeHeader: tt.contentType}
+				if tt.contentEncoding != "" {
+					headers[ContentEncodingHeader] = tt.contentEncoding
+				}
+				return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: headers, Body: tt.body}, nil
+			})
+			actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{
+				Headers: map[string]string{"accept-encoding": tt.acceptEncoding},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedVary, actual.Headers[VaryHeader])
+
+			if tt.expectedEncoding == "" {
+				assert.Equal(t, tt.body, actual.Body)
+				assert.False(t, actual.IsBase64Encoded)
+				assert.Equal(t, tt.contentEncoding, actual.Headers[ContentEncodingHeader])
+				return
+			}
+			assert.Equal(t, tt.expectedEncoding, actual.Headers[ContentEncodingHeader])
+			assert.True(t, actual.IsBase64Encoded)
+			compressed, err := base64.StdEncoding.DecodeString(actual.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Less(t, len(compressed), len(tt.body))
+			r, err := readers[tt.expectedEncoding](bytes.NewReader(compressed))
+			if !assert.NoError(t, err) {
+				return
+			}
+			body, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+)
+
+const (
+	// ConsistentParameter is the query parameter asking for strongly consistent reads.
+	ConsistentParameter = "consistent"
+	// PreferHeader is the header asking for strongly consistent reads with "consistent" preference.
+	PreferHeader = "Prefer"
+	// ReadConsistencyHeader is the response header echoing consistency of reads,
+	// "strong" or "eventual".
+	ReadConsistencyHeader = "X-Read-Consistency"
+)
+
+var ErrorInvalidConsistentParameter = errors.New("invalid consistent query parameter")
+
+func init() {
+	RegisterError(ErrorInvalidConsistentParameter, http.StatusBadRequest, ErrorBadRequest)
+}
+
+// consistentRead reports whether the request asks for strongly consistent reads with
+// "consistent" query parameter or "Prefer: consistent" header.
+func consistentRead(request events.APIGatewayProxyRequest) (bool, error) {
+	if value, ok := request.QueryStringParameters[ConsistentParameter]; ok {
+		consistent, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrorInvalidConsistentParameter, value)
+		}
+		return consistent, nil
+	}
+
+	for name, value := range request.Headers {
+		if !strings.EqualFold(name, PreferHeader) {
+			continue
+		}
+		for _, preference := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "consistent") {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// WithReadConsistency wraps handler, so its reads are strongly consistent if the request
+// asks for it, and the consistency of reads is echoed in X-Read-Consistency header.
+func WithReadConsistency(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		consistent, err := consistentRead(request)
+		if err != nil {
+			return errorResponse(ctx, err)
+		}
+
+		consistency := "eventual"
+		if consistent {
+			ctx = repository.WithConsistentRead(ctx)
+			consistency = "strong"
+		}
+
+		response, err := handler(ctx, request)
+		if response != nil {
+			if response.Headers == nil {
+				response.Headers = map[string]string{}
+			}
+			response.Headers[ReadConsistencyHeader] = consistency
+		}
+		return response, err
+	}
+}
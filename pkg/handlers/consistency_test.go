@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithReadConsistency tests the WithReadConsistency wrapper to ensure requests asking
+// for strongly consistent reads with query parameter or Prefer header get them, the mode is
+// echoed in the response, and invalid query parameters are rejected.
+func TestWithReadConsistency(t *testing.T) {
+	handler := WithReadConsistency(func(ctx context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return buildAPIResponse(context.TODO(), http.StatusOK, repository.ConsistentRead(ctx))
+	})
+
+	tests := []struct {
+		name                string
+		request             events.APIGatewayProxyRequest
+		expectedStatus      int
+		expectedBody        string
+		expectedConsistency string
+	}{
+		{
+			name:                "Default",
+			request:             events.APIGatewayProxyRequest{},
+			expectedStatus:      http.StatusOK,
+			expectedBody:        "false",
+			expectedConsistency: "eventual",
+		},
+		{
+			name:                "Query parameter",
+			request:             events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"consistent": "true"}},
+			expectedStatus:      http.StatusOK,
+			expectedBody:        "true",
+			expectedConsistency: "strong",
+		},
+		{
+			name:                "Prefer header",
+			request:             events.APIGatewayProxyRequest{Headers: map[string]string{"prefer": "return=minimal, consistent"}},
+			expectedStatus:      http.StatusOK,
+			expectedBody:        "true",
+			expectedConsistency: "strong",
+		},
+		{
+			name: "Query parameter overrides header",
+			request: events.APIGatewayProxyRequest{
+				QueryStringParameters: map[string]string{"consistent": "false"},
+				Headers:               map[string]string{"Prefer": "consistent"},
+			},
+			expectedStatus:      http.StatusOK,
+			expectedBody:        "false",
+			expectedConsistency: "eventual",
+		},
+		{
+			name:           "Invalid query parameter",
+			request:        events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"consistent": "maybe"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   problemJSON(t, http.StatusBadRequest, "invalid-consistent-parameter", "invalid consistent query parameter: maybe"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, _ := handler(context.TODO(), tt.request)
+			assert.Equal(t, tt.expectedStatus, actual.StatusCode)
+			assert.JSONEq(t, tt.expectedBody, actual.Body)
+			assert.Equal(t, tt.expectedConsistency, actual.Headers[ReadConsistencyHeader])
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+// Headers of CORS requests and responses.
+const (
+	OriginHeader                        = "Origin"
+	VaryHeader                          = "Vary"
+	AccessControlRequestMethodHeader    = "Access-Control-Request-Method"
+	AccessControlRequestHeadersHeader   = "Access-Control-Request-Headers"
+	AccessControlAllowOriginHeader      = "Access-Control-Allow-Origin"
+	AccessControlAllowMethodsHeader     = "Access-Control-Allow-Methods"
+	AccessControlAllowHeadersHeader     = "Access-Control-Allow-Headers"
+	AccessControlAllowCredentialsHeader = "Access-Control-Allow-Credentials"
+	AccessControlExposeHeadersHeader    = "Access-Control-Expose-Headers"
+	AccessControlMaxAgeHeader           = "Access-Control-Max-Age"
+)
+
+var ErrorInvalidCORSPolicy = errors.New("invalid CORS policy: credentials can not be allowed for any origin (\"*\")")
+
+// CORSPolicy configures cross-origin requests of browsers.
+type CORSPolicy struct {
+	// AllowedOrigins are origins allowed to call the API, e.g. "https://app.example.com",
+	// "https://*.example.com" (any subdomain) or "*" (any origin). CORS is disabled if empty.
+	AllowedOrigins []string
+	// AllowedMethods are methods allowed in cross-origin requests.
+	AllowedMethods []string
+	// AllowedHeaders are request headers allowed in cross-origin requests, "*" allows any.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers browsers expose to cross-origin callers.
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and Authorization header in cross-origin requests.
+	AllowCredentials bool
+	// MaxAge is the time browsers cache responses to preflight requests for.
+	MaxAge time.Duration
+}
+
+// DefaultCORSPolicy keeps CORS disabled until origins are allowed, with methods and
+// headers the API uses.
+var DefaultCORSPolicy = CORSPolicy{
+	AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+	AllowedHeaders: []string{"Authorization", "Content-Type", "Cache-Control", PreferHeader, TenantHeader, RequestIDHeader},
+	ExposedHeaders: []string{
+		AllowHeader, DeprecationHeader, LinkHeader, "Retry-After", ReadConsistencyHeader, RequestIDHeader,
+	},
+	MaxAge: 10 * time.Minute,
+}
+
+var corsPolicy = DefaultCORSPolicy
+
+func init() {
+	// Load CORS policy, a policy letting any site make credentialed requests is rejected.
+	p, err := corsPolicyFromEnv()
+	if err != nil {
+		logging.Fatalf("%v", err)
+	}
+	corsPolicy = p
+}
+
+// SetCORSPolicy replaces the CORS policy, unless it is invalid.
+func SetCORSPolicy(p CORSPolicy) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	corsPolicy = p
+	return nil
+}
+
+// validate checks that the policy does not allow credentialed requests of any origin, which
+// would let any site read responses on behalf of the user.
+func (p CORSPolicy) validate() error {
+	if p.AllowCredentials && slices.Contains(p.AllowedOrigins, "*") {
+		return ErrorInvalidCORSPolicy
+	}
+	return nil
+}
+
+// corsPolicyFromEnv builds CORS policy from environment variables falling back to defaults.
+// Lists are comma separated.
+func corsPolicyFromEnv() (CORSPolicy, error) {
+	p := DefaultCORSPolicy
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		p.AllowedOrigins = splitList(v)
+	}
+	if v := os.Getenv("CORS_ALLOWED_METHODS"); v != "" {
+		p.AllowedMethods = splitList(v)
+	}
+	if v := os.Getenv("CORS_ALLOWED_HEADERS"); v != "" {
+		p.AllowedHeaders = splitList(v)
+	}
+	if v := os.Getenv("CORS_EXPOSED_HEADERS"); v != "" {
+		p.ExposedHeaders = splitList(v)
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS")); err == nil {
+		p.AllowCredentials = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("CORS_MAX_AGE")); err == nil && v >= 0 {
+		p.MaxAge = v
+	}
+	return p, p.validate()
+}
+
+// splitList returns trimmed non-empty elements of comma separated list.
+func splitList(list string) []string {
+	var elements []string
+	for _, e := range strings.Split(list, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+// headerValue returns value of request header, header names are case-insensitive.
+func headerValue(headers map[string]string, name string) string {
+	for n, value := range headers {
+		if strings.EqualFold(n, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// allowsOrigin reports whether origin is allowed by exact match, wildcard subdomain
+// ("https://*.example.com" matches "https://app.example.com" but not "https://example.com")
+// or "*".
+func (p CORSPolicy) allowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok {
+			prefix := strings.ToLower(scheme + "://")
+			suffix := strings.ToLower("." + domain)
+			o := strings.ToLower(origin)
+			if strings.HasPrefix(o, prefix) && strings.HasSuffix(o, suffix) && len(o) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether all comma separated request headers are allowed.
+func (p CORSPolicy) allowsHeaders(headers string) bool {
+	if slices.Contains(p.AllowedHeaders, "*") {
+		return true
+	}
+	for _, h := range splitList(headers) {
+		if !slices.ContainsFunc(p.AllowedHeaders, func(allowed string) bool { return strings.EqualFold(allowed, h) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowOrigin returns value of Access-Control-Allow-Origin header of allowed origin: "*" if
+// any origin is allowed (never with credentials), the origin otherwise.
+func (p CORSPolicy) allowOrigin(origin string) string {
+	if slices.Contains(p.AllowedOrigins, "*") && !p.AllowCredentials {
+		return "*"
+	}
+	return origin
+}
+
+// isPreflight reports whether request is CORS preflight request.
+func isPreflight(request events.APIGatewayProxyRequest) bool {
+	return request.HTTPMethod == "OPTIONS" &&
+		headerValue(request.Headers, OriginHeader) != "" &&
+		headerValue(request.Headers, AccessControlRequestMethodHeader) != ""
+}
+
+// preflight answers CORS preflight request, with 204 status code if the origin, method and
+// headers are allowed and 403 otherwise.
+func preflight(ctx context.Context, p CORSPolicy, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	origin := headerValue(request.Headers, OriginHeader)
+	method := headerValue(request.Headers, AccessControlRequestMethodHeader)
+	requestHeaders := headerValue(request.Headers, AccessControlRequestHeadersHeader)
+	if !p.allowsOrigin(origin) || !slices.Contains(p.AllowedMethods, method) || !p.allowsHeaders(requestHeaders) {
+		logging.PrintfContext(ctx, "CORS preflight rejected: %v %v %v", origin, method, requestHeaders)
+		response, err := buildAPIResponse(ctx, http.StatusForbidden, ErrorForbidden)
+		response.Headers[VaryHeader] = OriginHeader
+		return response, err
+	}
+
+	allowedHeaders := strings.Join(p.AllowedHeaders, ", ")
+	if slices.Contains(p.AllowedHeaders, "*") {
+		// Echo requested headers, "*" is not a wildcard in credentialed requests.
+		allowedHeaders = requestHeaders
+	}
+	headers := map[string]string{
+		AccessControlAllowOriginHeader:  p.allowOrigin(origin),
+		AccessControlAllowMethodsHeader: strings.Join(p.AllowedMethods, ", "),
+		AccessControlMaxAgeHeader:       strconv.Itoa(int(p.MaxAge.Seconds())),
+		VaryHeader:                      OriginHeader,
+	}
+	if allowedHeaders != "" {
+		headers[AccessControlAllowHeadersHeader] = allowedHeaders
+	}
+	if p.AllowCredentials {
+		headers[AccessControlAllowCredentialsHeader] = "true"
+	}
+	return &events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent, Headers: headers}, nil
+}
+
+// WithCORS wraps handler, so CORS preflight requests are answered directly and responses
+// to requests of allowed origins carry CORS headers of the CORS policy.
+func WithCORS(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		p := corsPolicy
+		if isPreflight(request) {
+			return preflight(ctx, p, request)
+		}
+
+		response, err := handler(ctx, request)
+		if response == nil || len(p.AllowedOrigins) == 0 {
+			return response, err
+		}
+
+		// Responses depend on the origin even if it is not allowed, so caches must tell them apart.
+		if response.Headers == nil {
+			response.Headers = map[string]string{}
+		}
+		addVary(response.Headers, OriginHeader)
+		origin := headerValue(request.Headers, OriginHeader)
+		if !p.allowsOrigin(origin) {
+			return response, err
+		}
+		response.Headers[AccessControlAllowOriginHeader] = p.allowOrigin(origin)
+		if p.AllowCredentials {
+			response.Headers[AccessControlAllowCredentialsHeader] = "true"
+		}
+		if len(p.ExposedHeaders) > 0 {
+			response.Headers[AccessControlExposeHeadersHeader] = strings.Join(p.ExposedHeaders, ", ")
+		}
+		return response, err
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithCORS tests the WithCORS wrapper to ensure preflight requests of allowed origins
+// (exact or wildcard subdomain), methods and headers are answered directly, others are
+// forbidden, responses to allowed origins carry CORS headers while other responses only vary
+// by Origin, and credentials are never allowed for any origin.
+func TestWithCORS(t *testing.T) {
+	previous := corsPolicy
+	defer SetCORSPolicy(previous)
+
+	handler := WithCORS(func(_ context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		response, err := buildAPIResponse(context.TODO(), http.StatusOK, "ok")
+		response.Headers[VaryHeader] = "Accept"
+		return response, err
+	})
+	policy := CORSPolicy{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{AllowHeader},
+		MaxAge:         time.Hour,
+	}
+	credentials := policy
+	credentials.AllowCredentials = true
+	anyOrigin := policy
+	anyOrigin.AllowedOrigins = []string{"*"}
+
+	preflightRequest := func(origin, method, headers string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod: "OPTIONS",
+			Headers: map[string]string{
+				"origin":                         origin,
+				"access-control-request-method":  method,
+				"access-control-request-headers": headers,
+			},
+		}
+	}
+	get := func(origin string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{HTTPMethod: "GET", Headers: map[string]string{"Origin": origin}}
+	}
+
+	tests := []struct {
+		name            string
+		policy          CORSPolicy
+		request         events.APIGatewayProxyRequest
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "Preflight of exact origin",
+			policy:         policy,
+			request:        preflightRequest("https://app.example.com", "PUT", "content-type, authorization"),
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader:  "https://app.example.com",
+				AccessControlAllowMethodsHeader: "GET, PUT",
+				AccessControlAllowHeadersHeader: "Authorization, Content-Type",
+				AccessControlMaxAgeHeader:       "3600",
+				VaryHeader:                      OriginHeader,
+			},
+		},
+		{
+			name:           "Preflight of wildcard subdomain",
+			policy:         policy,
+			request:        preflightRequest("https://eu.app.example.org", "GET", ""),
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader: "https://eu.app.example.org",
+			},
+		},
+		{
+			name:            "Preflight of wildcard domain itself",
+			policy:          policy,
+			request:         preflightRequest("https://example.org", "GET", ""),
+			expectedStatus:  http.StatusForbidden,
+			expectedHeaders: map[string]string{AccessControlAllowOriginHeader: "", VaryHeader: OriginHeader},
+		},
+		{
+			name:            "Preflight of disallowed scheme",
+			policy:          policy,
+			request:         preflightRequest("http://app.example.com", "GET", ""),
+			expectedStatus:  http.StatusForbidden,
+			expectedHeaders: map[string]string{AccessControlAllowOriginHeader: ""},
+		},
+		{
+			name:            "Preflight of disallowed method",
+			policy:          policy,
+			request:         preflightRequest("https://app.example.com", "DELETE", ""),
+			expectedStatus:  http.StatusForbidden,
+			expectedHeaders: map[string]string{AccessControlAllowOriginHeader: ""},
+		},
+		{
+			name:            "Preflight of disallowed header",
+			policy:          policy,
+			request:         preflightRequest("https://app.example.com", "GET", "X-Custom"),
+			expectedStatus:  http.StatusForbidden,
+			expectedHeaders: map[string]string{AccessControlAllowOriginHeader: ""},
+		},
+		{
+			name:            "Preflight with CORS disabled",
+			policy:          DefaultCORSPolicy,
+			request:         preflightRequest("https://app.example.com", "GET", ""),
+			expectedStatus:  http.StatusForbidden,
+			expectedHeaders: map[string]string{AccessControlAllowOriginHeader: ""},
+		},
+		{
+			name:           "Preflight with credentials echoes origin",
+			policy:         credentials,
+			request:        preflightRequest("https://eu.app.example.org", "GET", ""),
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader:      "https://eu.app.example.org",
+				AccessControlAllowCredentialsHeader: "true",
+			},
+		},
+		{
+			name:           "Request of allowed origin",
+			policy:         policy,
+			request:        get("https://app.example.com"),
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader:      "https://app.example.com",
+				AccessControlExposeHeadersHeader:    AllowHeader,
+				AccessControlAllowCredentialsHeader: "",
+				VaryHeader:                          "Accept, Origin",
+			},
+		},
+		{
+			name:           "Request of any origin",
+			policy:         anyOrigin,
+			request:        get("https://any.example.net"),
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader: "*",
+			},
+		},
+		{
+			name:           "Request of disallowed origin",
+			policy:         policy,
+			request:        get("https://evil.example.com"),
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader:   "",
+				AccessControlExposeHeadersHeader: "",
+				VaryHeader:                       "Accept, Origin",
+			},
+		},
+		{
+			name:           "Request with CORS disabled",
+			policy:         DefaultCORSPolicy,
+			request:        get("https://app.example.com"),
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				AccessControlAllowOriginHeader: "",
+				VaryHeader:                     "Accept",
+			},
+		},
+		{
+			name:           "OPTIONS request without preflight headers",
+			policy:         policy,
+			request:        events.APIGatewayProxyRequest{HTTPMethod: "OPTIONS"},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, SetCORSPolicy(tt.policy))
+			actual, err := handler(context.TODO(), tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, actual.StatusCode)
+			for name, value := range tt.expectedHeaders {
+				assert.Equal(t, value, actual.Headers[name], name)
+			}
+		})
+	}
+
+	// Credentials can not be allowed for any origin.
+	before := corsPolicy
+	anyOrigin.AllowCredentials = true
+	assert.ErrorIs(t, SetCORSPolicy(anyOrigin), ErrorInvalidCORSPolicy)
+	assert.Equal(t, before, corsPolicy)
+}
+
+// TestCORSPolicyFromEnv tests the corsPolicyFromEnv function to ensure comma separated lists,
+// credentials and max-age are read from environment variables, defaults are kept otherwise,
+// and credentials allowed for any origin are rejected.
+func TestCORSPolicyFromEnv(t *testing.T) {
+	actual, err := corsPolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultCORSPolicy, actual)
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://*.example.org,")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_MAX_AGE", "1h")
+	t.Setenv("CORS_EXPOSED_HEADERS", "Allow")
+	actual, err = corsPolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://app.example.com", "https://*.example.org"}, actual.AllowedOrigins)
+	assert.True(t, actual.AllowCredentials)
+	assert.Equal(t, time.Hour, actual.MaxAge)
+	assert.Equal(t, []string{"Allow"}, actual.ExposedHeaders)
+	assert.Equal(t, DefaultCORSPolicy.AllowedMethods, actual.AllowedMethods)
+
+	t.Setenv("CORS_MAX_AGE", "soon")
+	actual, err = corsPolicyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultCORSPolicy.MaxAge, actual.MaxAge)
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	_, err = corsPolicyFromEnv()
+	assert.ErrorIs(t, err, ErrorInvalidCORSPolicy)
+}
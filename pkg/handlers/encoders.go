@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// CSVValueColumn is the CSV column of array elements which are not objects.
+	CSVValueColumn = "value"
+	// XMLRootElement is the root element of XML bodies.
+	XMLRootElement = "response"
+	// XMLItemElement is the element of array elements of XML bodies.
+	XMLItemElement = "item"
+	// XMLFieldElement is the element of fields whose names are not valid XML names,
+	// with the name in its "name" attribute.
+	XMLFieldElement = "field"
+)
+
+// scalarString returns text of JSON scalar value, empty for null.
+func scalarString(v any) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// csvFormulaPrefixes are first characters of cells which spreadsheets run as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvText returns text of CSV cell prefixed with ', so spreadsheets show formulas as text
+// rather than run them (CSV injection).
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// EncodeCSV encodes array of objects into CSV with a header of fields in order of their
+// first appearance, single object into one row. Nested objects and arrays are encoded as
+// JSON in their cells. Field names and strings which spreadsheets would run as formulas
+// are prefixed with '.
+func EncodeCSV(v any) ([]byte, error) {
+	rows, ok := v.([]any)
+	if !ok {
+		rows = []any{v}
+	}
+
+	// Collect columns of all rows.
+	var columns []string
+	index := map[string]int{}
+	records := make([]Object, len(rows))
+	for i, row := range rows {
+		o, ok := row.(Object)
+		if !ok {
+			o = Object{{Name: CSVValueColumn, Value: row}}
+		}
+		for _, m := range o {
+			if _, ok := index[m.Name]; !ok {
+				index[m.Name] = len(columns)
+				columns = append(columns, csvText(m.Name))
+			}
+		}
+		records[i] = o
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, o := range records {
+		record := make([]string, len(columns))
+		for _, m := range o {
+			cell, ok := scalarString(m.Value)
+			if s, isString := m.Value.(string); isString {
+				cell = csvText(s)
+			}
+			if !ok {
+				j, err := json.Marshal(m.Value)
+				if err != nil {
+					return nil, err
+				}
+				cell = string(j)
+			}
+			record[index[m.Name]] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// EncodeYAML encodes value into YAML keeping order of fields of objects.
+func EncodeYAML(v any) ([]byte, error) {
+	node, err := yamlNode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(2)
+	if err := e.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// yamlNode returns YAML node of value decoded from JSON.
+func yamlNode(v any) (*yaml.Node, error) {
+	switch v := v.(type) {
+	case Object:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, m := range v {
+			value, err := yamlNode(m.Value)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: m.Name}, value)
+		}
+		return n, nil
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, e := range v {
+			value, err := yamlNode(e)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, value)
+		}
+		return n, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}, nil
+	case json.Number:
+		tag := "!!float"
+		if _, err := v.Int64(); err == nil {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}, nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrorUnsupportedValue, v)
+}
+
+// EncodeXML encodes value into XML with the root element "response", fields of objects as
+// elements and elements of arrays as "item" elements.
+func EncodeXML(v any) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	e := xml.NewEncoder(&b)
+	if err := encodeXMLElement(e, XMLRootElement, v); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// encodeXMLElement encodes value into XML element of name.
+func encodeXMLElement(e *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: XMLFieldElement},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: name}},
+		}
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case Object:
+		for _, m := range v {
+			if err := encodeXMLElement(e, m.Name, m.Value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := encodeXMLElement(e, XMLItemElement, item); err != nil {
+				return err
+			}
+		}
+	default:
+		text, ok := scalarString(v)
+		if !ok {
+			return fmt.Errorf("%w: %T", ErrorUnsupportedValue, v)
+		}
+		if err := e.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// isXMLName reports whether name is valid XML element name without namespace prefix.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
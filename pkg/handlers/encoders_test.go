@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEncoders tests the EncodeCSV, EncodeYAML and EncodeXML functions to ensure values
+// decoded from JSON keep order of fields, nested values and special characters are encoded,
+// strings looking like other types stay strings, and CSV cells looking like formulas are
+// prefixed with '.
+func TestEncoders(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCSV  string
+		expectedYAML string
+		expectedXML  string
+	}{
+		{
+			name:         "Array of objects with different fields",
+			body:         `[{"email":"a@b.com","age":37},{"email":"c@d.com","lastName":"O'Neil, Jr."}]`,
+			expectedCSV:  "email,age,lastName\na@b.com,37,\nc@d.com,,\"O'Neil, Jr.\"\n",
+			expectedYAML: "- email: a@b.com\n  age: 37\n- email: c@d.com\n  lastName: O'Neil, Jr.\n",
+			expectedXML:  "<response><item><email>a@b.com</email><age>37</age></item><item><email>c@d.com</email><lastName>O&#39;Neil, Jr.</lastName></item></response>",
+		},
+		{
+			name:         "Nested object",
+			body:         `{"count":2,"byAge":{"30":1,"40-49":1},"ratio":0.5,"active":true,"note":null}`,
+			expectedCSV:  "count,byAge,ratio,active,note\n2,\"{\"\"30\"\":1,\"\"40-49\"\":1}\",0.5,true,\n",
+			expectedYAML: "count: 2\nbyAge:\n  \"30\": 1\n  40-49: 1\nratio: 0.5\nactive: true\nnote: null\n",
+			expectedXML:  `<response><count>2</count><byAge><field name="30">1</field><field name="40-49">1</field></byAge><ratio>0.5</ratio><active>true</active><note></note></response>`,
+		},
+		{
+			name:         "Array of strings looking like other types",
+			body:         `["true","1","<a>"]`,
+			expectedCSV:  "value\ntrue\n1\n<a>\n",
+			expectedYAML: "- \"true\"\n- \"1\"\n- <a>\n",
+			expectedXML:  "<response><item>true</item><item>1</item><item>&lt;a&gt;</item></response>",
+		},
+		{
+			name:         "Object with formulas",
+			body:         `{"firstName":"=HYPERLINK(\"http://evil\")","lastName":"@SUM(A1)","note":"\tx","age":-5,"@id":"-1"}`,
+			expectedCSV:  "firstName,lastName,note,age,'@id\n\"'=HYPERLINK(\"\"http://evil\"\")\",'@SUM(A1),'\tx,-5,'-1\n",
+			expectedYAML: "firstName: =HYPERLINK(\"http://evil\")\nlastName: '@SUM(A1)'\nnote: \"\\tx\"\nage: -5\n'@id': \"-1\"\n",
+			expectedXML:  `<response><firstName>=HYPERLINK(&#34;http://evil&#34;)</firstName><lastName>@SUM(A1)</lastName><note>&#x9;x</note><age>-5</age><field name="@id">-1</field></response>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeJSON(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := EncodeCSV(v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCSV, string(actual))
+
+			actual, err = EncodeYAML(v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedYAML, string(actual))
+
+			actual, err = EncodeXML(v)
+			assert.NoError(t, err)
+			assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+tt.expectedXML, string(actual))
+		})
+	}
+}
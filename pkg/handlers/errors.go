@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+)
+
+// errorMapping maps domain errors matched by match to HTTP status code and public error.
+type errorMapping struct {
+	match  func(error) bool
+	status int
+	public error
+}
+
+// errorMappings are checked in registration order, the first matching one wins.
+var errorMappings []errorMapping
+
+func init() {
+	RegisterErrorType[*repository.RetryExhaustedError](http.StatusServiceUnavailable, ErrorServiceUnavailable)
+	RegisterError(user.ErrorUserDoesNotExist, http.StatusNotFound, ErrorNotFound)
+	RegisterError(user.ErrorFailedToValidateUser, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(user.ErrorInvalidField, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(user.ErrorInvalidFilter, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(user.ErrorInvalidSort, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(ErrorInvalidJSON, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(tenant.ErrorInvalidTenantID, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(tenant.ErrorTenantMismatch, http.StatusForbidden, ErrorForbidden)
+	RegisterError(tenant.ErrorUntrustedTenant, http.StatusForbidden, ErrorForbidden)
+	RegisterError(ErrorNoEmailQueryParameter, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(ErrorNoIDQueryParameter, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(ErrorNoEmailPathParameter, http.StatusBadRequest, ErrorBadRequest)
+	RegisterError(ErrorNoIDPathParameter, http.StatusBadRequest, ErrorBadRequest)
+}
+
+// RegisterError registers HTTP status code and public error for errors matching target
+// with errors.Is. It should be called during initialization (e.g. from init function).
+func RegisterError(target error, status int, public error) {
+	RegisterErrorFunc(func(err error) bool { return errors.Is(err, target) }, status, public)
+}
+
+// RegisterErrorType registers HTTP status code and public error for errors of type T
+// matched with errors.As. It should be called during initialization (e.g. from init function).
+func RegisterErrorType[T error](status int, public error) {
+	RegisterErrorFunc(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, status, public)
+}
+
+// RegisterErrorFunc registers HTTP status code and public error for errors for which match
+// returns true. It should be called during initialization (e.g. from init function).
+func RegisterErrorFunc(match func(error) bool, status int, public error) {
+	errorMappings = append(errorMappings, errorMapping{match: match, status: status, public: public})
+}
+
+// mapErrorToResponse maps business logic errors to the HTTP response errors and status codes.
+// Errors without registered mapping are mapped to internal server error.
+func mapErrorToResponse(ctx context.Context, err error) (int, error) {
+	logging.PrintfContext(ctx, "mapErrorToResponse: %v", err)
+	for _, m := range errorMappings {
+		if m.match(err) {
+			return m.status, m.public
+		}
+	}
+	return http.StatusInternalServerError, ErrorInternalServerError
+}
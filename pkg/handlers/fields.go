@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FieldsParameter is the query parameter selecting a sparse fieldset of the response.
+const FieldsParameter = "fields"
+
+// parseFields returns fields of comma separated "fields" query parameter or nil if there
+// is none. Fields are validated by the entity package fetching them.
+func parseFields(parameters map[string]string) []string {
+	value := strings.TrimSpace(parameters[FieldsParameter])
+	if value == "" {
+		return nil
+	}
+
+	var fields []string
+	for _, f := range strings.Split(value, ",") {
+		fields = append(fields, strings.TrimSpace(f))
+	}
+	return fields
+}
+
+// projectFields returns JSON object (or array of objects) v limited to fields. It returns
+// v as is if there are no fields.
+func projectFields(v any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInternalServerError, err)
+	}
+
+	project := func(object map[string]json.RawMessage) map[string]json.RawMessage {
+		projected := map[string]json.RawMessage{}
+		for _, f := range fields {
+			if value, ok := object[f]; ok {
+				projected[f] = value
+			}
+		}
+		return projected
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(b, &object); err == nil {
+		return project(object), nil
+	}
+
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(b, &objects); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInternalServerError, err)
+	}
+	if objects == nil {
+		return v, nil
+	}
+	projected := make([]map[string]json.RawMessage, len(objects))
+	for i, o := range objects {
+		projected[i] = project(o)
+	}
+	return projected, nil
+}
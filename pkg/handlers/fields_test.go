@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFields tests the GetUser and GetUsers handlers with "fields" query parameter to
+// ensure responses include only requested fields and unknown fields are rejected.
+func TestFields(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	actual, _ := CreateUser(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidUser})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
+	projected := fmt.Sprintf(`{"email":"%v","firstName":"%v"}`, testutil.ValidUser1.Email, testutil.ValidUser1.FirstName)
+
+	tests := []struct {
+		name     string
+		handler  func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		request  events.APIGatewayProxyRequest
+		expected events.APIGatewayProxyResponse
+	}{
+		{
+			name:    "User fields",
+			handler: GetUser,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"email": testutil.ValidUser1.Email, "fields": "email, firstName"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: projected},
+		},
+		{
+			name:    "Users fields",
+			handler: GetUsers,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"fields": "email,firstName"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + projected + "]"},
+		},
+		{
+			name:    "Unknown field",
+			handler: GetUsers,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"fields": "email,password"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: problemJSON(t, http.StatusBadRequest, "invalid-field", "invalid field: password")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, _ := tt.handler(context.TODO(), tt.request)
+			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
+			assert.JSONEq(t, tt.expected.Body, actual.Body)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/group"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+)
+
+var (
+	ErrorNoIDQueryParameter = errors.New("no id query parameter")
+)
+
+func init() {
+	RegisterError(group.ErrorGroupDoesNotExist, http.StatusNotFound, ErrorNotFound)
+	RegisterError(group.ErrorMembershipDoesNotExist, http.StatusNotFound, ErrorNotFound)
+	RegisterError(group.ErrorFailedToValidateGroup, http.StatusBadRequest, ErrorBadRequest)
+}
+
+// unmarshalGroup unmarshals group from body of request strictly.
+func unmarshalGroup(ctx context.Context, request events.APIGatewayProxyRequest) (*models.Group, error) {
+	var g models.Group
+	err := decodeBody(request, &g)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorInvalidJSON, err)
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidJSON, err)
+	}
+	logging.PrintfContext(ctx, "Group: %v", g)
+
+	return &g, nil
+}
+
+// unmarshalMembership unmarshals membership from body of request strictly.
+func unmarshalMembership(ctx context.Context, request events.APIGatewayProxyRequest) (*models.Membership, error) {
+	var m models.Membership
+	err := decodeBody(request, &m)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorInvalidJSON, err)
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidJSON, err)
+	}
+	logging.PrintfContext(ctx, "Membership: %v", m)
+
+	return &m, nil
+}
+
+// GetGroup gets group data from DynamoDB and responds.
+func GetGroup(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract group's ID from request.
+	id := parameter(request, "id")
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDQueryParameter)
+	}
+
+	// Fetch group.
+	g, err := group.FetchGroup(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, g)
+}
+
+// GetGroups gets groups' data from DynamoDB table and responds.
+func GetGroups(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Fetch groups.
+	groups, err := group.FetchGroups(ctx)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, groups)
+}
+
+// CreateGroup creates group in DynamoDB table and responds.
+func CreateGroup(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Unmarshal received group JSON data.
+	g, err := unmarshalGroup(ctx, request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Create group.
+	err = group.CreateGroup(ctx, *g)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusCreated, g)
+}
+
+// UpdateGroup updates group data in DynamoDB table and responds. ID of the group is taken
+// from the path if given.
+func UpdateGroup(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Unmarshal received group JSON data.
+	g, err := unmarshalGroup(ctx, request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	if id := request.PathParameters["id"]; id != "" {
+		g.ID = id
+	}
+
+	// Update group.
+	err = group.UpdateGroup(ctx, *g)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, g)
+}
+
+// DeleteGroup deletes group and its memberships from DynamoDB table and responds.
+func DeleteGroup(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract group's ID from request.
+	id := parameter(request, "id")
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDQueryParameter)
+	}
+
+	// Delete group.
+	g, err := group.DeleteGroup(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, g)
+}
+
+// GetGroupMembers gets users who are members of group and responds.
+func GetGroupMembers(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract group's ID from request.
+	id := parameter(request, "id")
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDQueryParameter)
+	}
+
+	// Fetch members.
+	users, err := group.FetchMembers(ctx, id)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, users)
+}
+
+// AddGroupMember adds user from body to group and responds.
+func AddGroupMember(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract group's ID from request.
+	id := parameter(request, "id")
+	if id == "" {
+		return errorResponse(ctx, ErrorNoIDQueryParameter)
+	}
+
+	// Unmarshal received membership JSON data.
+	m, err := unmarshalMembership(ctx, request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	m.GroupID = id
+
+	// Add member.
+	err = group.AddMember(ctx, *m)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusCreated, m)
+}
+
+// RemoveGroupMember removes user from group and responds.
+func RemoveGroupMember(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract group's ID and user's email from request.
+	m := models.Membership{
+		GroupID: parameter(request, "id"),
+		Email:   parameter(request, "email"),
+	}
+	if m.GroupID == "" {
+		return errorResponse(ctx, ErrorNoIDQueryParameter)
+	}
+	if m.Email == "" {
+		return errorResponse(ctx, ErrorNoEmailQueryParameter)
+	}
+
+	// Remove member.
+	err := group.RemoveMember(ctx, m)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, m)
+}
+
+// GetUserGroups gets groups the user is a member of and responds.
+func GetUserGroups(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract users's email from request.
+	email := parameter(request, "email")
+	if email == "" {
+		return errorResponse(ctx, ErrorNoEmailQueryParameter)
+	}
+
+	// Fetch user's groups.
+	groups, err := group.FetchUserGroups(ctx, email)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, groups)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGroups tests the group handlers in sequence against in-memory repository to ensure
+// groups are created, listed, updated and deleted, and members are added, listed from both
+// sides and removed. It verifies the status codes and bodies of successful responses, and
+// that missing parameters, invalid data and non-existing entities are mapped to errors.
+func TestGroups(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	actual, _ := CreateUser(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidUser})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
+	groupID := map[string]string{"id": testutil.ValidGroup1.ID}
+	membership := fmt.Sprintf(`{"groupId":"%v","email":"%v"}`, testutil.ValidGroup1.ID, testutil.ValidUser1.Email)
+
+	tests := []struct {
+		name     string
+		handler  func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		request  events.APIGatewayProxyRequest
+		expected events.APIGatewayProxyResponse
+	}{
+		{
+			name:     "Create group",
+			handler:  CreateGroup,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidGroup},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusCreated, Body: testutil.ValidGroup},
+		},
+		{
+			name:    "Create invalid group",
+			handler: CreateGroup,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: `{"name":"test"}`},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body: problemJSON(t, http.StatusBadRequest, "invalid-group",
+					"failed to validate group: Key: 'Group.ID' Error:Field validation for 'ID' failed on the 'required' tag"),
+			},
+		},
+		{
+			name:     "Get group",
+			handler:  GetGroup,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: groupID},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: testutil.ValidGroup},
+		},
+		{
+			name:    "Get group without ID",
+			handler: GetGroup,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "GET"},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       problemJSON(t, http.StatusBadRequest, "no-id-query-parameter", ""),
+			},
+		},
+		{
+			name:     "Get groups",
+			handler:  GetGroups,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET"},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + testutil.ValidGroup + "]"},
+		},
+		{
+			name:    "Update non-existing group",
+			handler: UpdateGroup,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "PUT", Body: `{"id":"test"}`},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       problemJSON(t, http.StatusNotFound, "group-not-found", "group does not exist: test"),
+			},
+		},
+		{
+			name:    "Add non-existing member",
+			handler: AddGroupMember,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "POST",
+				QueryStringParameters: groupID,
+				Body:                  fmt.Sprintf(`{"email":"%v"}`, testutil.InvalidUser1.Email),
+			},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       problemJSON(t, http.StatusNotFound, "user-not-found", "user does not exist: [email:e090d6e2]"),
+			},
+		},
+		{
+			name:    "Add member",
+			handler: AddGroupMember,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "POST",
+				QueryStringParameters: groupID,
+				Body:                  fmt.Sprintf(`{"email":"%v"}`, testutil.ValidUser1.Email),
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusCreated, Body: membership},
+		},
+		{
+			name:     "Get members",
+			handler:  GetGroupMembers,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: groupID},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + testutil.ValidUser + "]"},
+		},
+		{
+			name:    "Get user's groups",
+			handler: GetUserGroups,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				QueryStringParameters: testutil.ValidQueQueryStringParameters,
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + testutil.ValidGroup + "]"},
+		},
+		{
+			name:    "Remove member without email",
+			handler: RemoveGroupMember,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "DELETE", QueryStringParameters: groupID},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       problemJSON(t, http.StatusBadRequest, "no-email-query-parameter", ""),
+			},
+		},
+		{
+			name:    "Remove member",
+			handler: RemoveGroupMember,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "DELETE",
+				QueryStringParameters: map[string]string{"id": testutil.ValidGroup1.ID, "email": testutil.ValidUser1.Email},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: membership},
+		},
+		{
+			name:    "Remove non-existing member",
+			handler: RemoveGroupMember,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "DELETE",
+				QueryStringParameters: map[string]string{"id": testutil.ValidGroup1.ID, "email": testutil.ValidUser1.Email},
+			},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       problemJSON(t, http.StatusNotFound, "membership-not-found", "membership does not exist: {engineering [email:62e650e5]}"),
+			},
+		},
+		{
+			name:     "Delete group",
+			handler:  DeleteGroup,
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "DELETE", QueryStringParameters: groupID},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: testutil.ValidGroup},
+		},
+		{
+			name:    "Get deleted group",
+			handler: GetGroup,
+			request: events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: groupID},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       problemJSON(t, http.StatusNotFound, "group-not-found", "group does not exist: engineering"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, _ := tt.handler(context.TODO(), tt.request)
+			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
+			assert.JSONEq(t, tt.expected.Body, actual.Body)
+		})
+	}
+}
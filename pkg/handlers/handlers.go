@@ -2,12 +2,13 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
 )
@@ -19,143 +20,135 @@ var (
 	ErrorBadRequest            = errors.New("bad request")
 	ErrorNotFound              = errors.New("not found")
 	ErrorInternalServerError   = errors.New("internal server error")
+	ErrorServiceUnavailable    = errors.New("service unavailable")
+	ErrorForbidden             = errors.New("forbidden")
 )
 
-type ErrorBody struct {
-	ErrorMsg *string `json:"error,omitempty"`
-}
-
-// unmarshalUser unmarshals user from body.
-func unmarshalUser(body string) (*models.User, error) {
+// unmarshalUser unmarshals user from body of request strictly.
+func unmarshalUser(ctx context.Context, request events.APIGatewayProxyRequest) (*models.User, error) {
 	var u models.User
-	err := json.Unmarshal([]byte(body), &u)
+	err := decodeBody(request, &u)
 	if err != nil {
-		log.Printf("%v: %v", ErrorInvalidJSON, err)
-		return nil, ErrorInvalidJSON
+		logging.PrintfContext(ctx, "%v: %v", ErrorInvalidJSON, err)
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidJSON, err)
 	}
-	log.Printf("User: %v", u)
+	logging.PrintfContext(ctx, "User: %v", u)
 
 	return &u, nil
 }
 
-// mapErrorToResponse maps business logic errors to the HTTP response errors and status codes.
-func mapErrorToResponse(err error) (int, error) {
-	switch err {
-	case user.ErrorFailedToGetItem, user.ErrorFailedToGetItems, user.ErrorFailedToPutItem,
-		user.ErrorFailedToDeleteItem, user.ErrorFailedToUnmarshalMap:
-		return http.StatusInternalServerError, ErrorInternalServerError
-	case user.ErrorUserDoesNotExist:
-		return http.StatusNotFound, ErrorNotFound
-	case user.ErrorFailedToValidateUser, ErrorInvalidJSON:
-		return http.StatusBadRequest, ErrorBadRequest
-	default:
-		return http.StatusInternalServerError, ErrorInternalServerError
-	}
-}
-
 // GetUser gets user data from DynamoDB and responds.
-func GetUser(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+func GetUser(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 	// Extract users's email from request.
-	email := request.QueryStringParameters["email"]
+	email := parameter(request, "email")
 	if email == "" {
-		log.Printf("%v", ErrorNoEmailQueryParameter)
-		return buildAPIResponse(http.StatusBadRequest, ErrorBadRequest)
+		return errorResponse(ctx, ErrorNoEmailQueryParameter)
 	}
-	log.Printf("query parameter email: %v", email)
+	logging.PrintfContext(ctx, "query parameter email: %v", email)
 
-	// Fetch user.
-	u, err := user.FetchUser(email)
+	// Fetch user, from cache unless the request asks for a fresh one.
+	fields := parseFields(request.QueryStringParameters)
+	u, err := user.FetchUser(withCacheControl(ctx, request), email, fields...)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
 	}
 
-	// Send successful response.
-	return buildAPIResponse(http.StatusOK, u)
+	// Send successful response with requested fields.
+	body, err := projectFields(u, fields)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return buildAPIResponse(ctx, http.StatusOK, body)
 }
 
 // GetUsers gets users' data from DynamoDB table and responds.
-func GetUsers() (*events.APIGatewayProxyResponse, error) {
+func GetUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract fields, filter and sort of users from request.
+	opts, err := listOptions(request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
 	// Fetch users.
-	users, err := user.FetchUsers()
+	users, err := user.FetchUsers(ctx, opts)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
 	}
 
-	// Send successful response.
-	return buildAPIResponse(http.StatusOK, users)
+	// Send successful response with requested fields.
+	body, err := projectFields(users, opts.Fields)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+	return buildAPIResponse(ctx, http.StatusOK, body)
 }
 
 // CreateUser creates user in DynamoDB table and responds.
-func CreateUser(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+func CreateUser(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 	// Unmarshal received user JSON data.
-	u, err := unmarshalUser(request.Body)
+	u, err := unmarshalUser(ctx, request)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
 	}
 
 	// Check email existence.
 	if u.Email == "" {
-		return buildAPIResponse(http.StatusBadRequest, ErrorBadRequest)
+		return errorResponse(ctx, fmt.Errorf("%w: email is required", user.ErrorFailedToValidateUser))
 	}
 
 	// Create user.
-	err = user.CreateUser(*u)
+	err = user.CreateUser(ctx, *u)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
 	}
 
 	// Send successful response.
-	return buildAPIResponse(http.StatusCreated, u)
+	return buildAPIResponse(ctx, http.StatusCreated, u)
 }
 
-// UpdateUser updates user data in DynamoDB table and responds.
-func UpdateUser(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+// UpdateUser updates user data in DynamoDB table and responds. Email of the user is
+// taken from the path if given.
+func UpdateUser(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 	// Unmarshal received user JSON data.
-	u, err := unmarshalUser(request.Body)
+	u, err := unmarshalUser(ctx, request)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
+	}
+
+	if email := request.PathParameters["email"]; email != "" {
+		u.Email = email
 	}
 
 	// Update user.
-	err = user.UpdateUser(*u)
+	err = user.UpdateUser(ctx, *u)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
 	}
 
 	// Send successful response.
-	return buildAPIResponse(http.StatusOK, u)
+	return buildAPIResponse(ctx, http.StatusOK, u)
 }
 
 // DeleteUser deletes user data from DynamoDB table and responds.
-func DeleteUser(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+func DeleteUser(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 	// Extract users's email from request.
-	email := request.QueryStringParameters["email"]
+	email := parameter(request, "email")
 	if email == "" {
-		log.Printf("%v", ErrorNoEmailQueryParameter)
-		return buildAPIResponse(http.StatusBadRequest, ErrorBadRequest)
+		return errorResponse(ctx, ErrorNoEmailQueryParameter)
 	}
-	log.Printf("query parameter email: %v", email)
+	logging.PrintfContext(ctx, "query parameter email: %v", email)
 
 	// Delete item from DynamoDB table.
-	u, err := user.DeleteUser(email)
+	u, err := user.DeleteUser(ctx, email)
 	if err != nil {
-		statusCode, errorMessage := mapErrorToResponse(err)
-		return buildAPIResponse(statusCode, errorMessage)
+		return errorResponse(ctx, err)
 	}
 
 	// Send successful response.
-	return buildAPIResponse(http.StatusOK, *u)
-}
-
-// UnhandledHTTPMethod responds for unsupported HTTP methods.
-func UnhandledHTTPMethod(
-	request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	log.Printf("unsupported HTTP method")
-	return buildAPIResponse(http.StatusMethodNotAllowed, ErrorMethodNotAllowed)
+	return buildAPIResponse(ctx, http.StatusOK, *u)
 }
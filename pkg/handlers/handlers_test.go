@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
 	"github.com/stretchr/testify/assert"
@@ -39,6 +44,12 @@ func TestUnmarshalUser(t *testing.T) {
 			expectedUser:  nil,
 			expectedError: ErrorInvalidJSON,
 		},
+		{
+			name:          "Unknown field",
+			requestBody:   `{"email":"a@b.com","role":"admin"}`,
+			expectedUser:  nil,
+			expectedError: ErrorInvalidJSON,
+		},
 		{
 			name:          "Empty User",
 			requestBody:   testutil.EmptyUser,
@@ -49,12 +60,10 @@ func TestUnmarshalUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualUser, err := unmarshalUser(tt.requestBody)
+			actualUser, err := unmarshalUser(context.TODO(), events.APIGatewayProxyRequest{Body: tt.requestBody})
 
 			if tt.expectedError != nil {
-				if assert.Error(t, err) {
-					assert.Equal(t, tt.expectedError, err)
-				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				if assert.NoError(t, err) {
 					assert.Equal(t, *tt.expectedUser, *actualUser)
@@ -69,6 +78,13 @@ func TestUnmarshalUser(t *testing.T) {
 // response errors and status codes. It verifies that different types of
 // errors are properly translated into appropriate HTTP responses.
 func TestMapErrorToResponse(t *testing.T) {
+	throttled := &repository.RetryExhaustedError{
+		Operation: "GetItem",
+		Attempts:  5,
+		Class:     repository.ErrorThrottled,
+		Err:       errors.New("ThrottlingException"),
+	}
+
 	tests := []struct {
 		name               string
 		inputError         error
@@ -99,6 +115,30 @@ func TestMapErrorToResponse(t *testing.T) {
 			expectedStatusCode: http.StatusInternalServerError,
 			expectedError:      ErrorInternalServerError,
 		},
+		{
+			name:               "RetryExhaustedError throttled",
+			inputError:         fmt.Errorf("%w: %w", user.ErrorFailedToGetItem, throttled),
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedError:      ErrorServiceUnavailable,
+		},
+		{
+			name:               "RetryExhaustedError transient failure",
+			inputError:         &repository.RetryExhaustedError{Class: repository.ErrorTransientFailure},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedError:      ErrorServiceUnavailable,
+		},
+		{
+			name:               "Wrapped ErrorUserDoesNotExist",
+			inputError:         fmt.Errorf("%w: %v", user.ErrorUserDoesNotExist, "test@test.com"),
+			expectedStatusCode: http.StatusNotFound,
+			expectedError:      ErrorNotFound,
+		},
+		{
+			name:               "Wrapped ErrorInvalidJSON",
+			inputError:         fmt.Errorf("%w: %w", ErrorInvalidJSON, errors.New("unexpected end of JSON input")),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      ErrorBadRequest,
+		},
 		{
 			name:               "ErrorUserDoesNotExist",
 			inputError:         user.ErrorUserDoesNotExist,
@@ -127,13 +167,96 @@ func TestMapErrorToResponse(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			statusCode, err := mapErrorToResponse(tt.inputError)
+			statusCode, err := mapErrorToResponse(context.TODO(), tt.inputError)
+			assert.Equal(t, tt.expectedStatusCode, statusCode)
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+}
+
+// teapotError is a domain error type used to test error registration.
+type teapotError struct{}
+
+func (teapotError) Error() string { return "teapot" }
+
+// TestRegisterError tests the error registration API to ensure registered domain errors
+// are mapped to their HTTP status codes and public errors, also when wrapped.
+func TestRegisterError(t *testing.T) {
+	errorGone := errors.New("gone")
+	errorPublicGone := errors.New("resource gone")
+	errorPublicTeapot := errors.New("I'm a teapot")
+
+	defer func(mappings []errorMapping) { errorMappings = mappings }(errorMappings)
+	RegisterError(errorGone, http.StatusGone, errorPublicGone)
+	RegisterErrorType[teapotError](http.StatusTeapot, errorPublicTeapot)
+
+	tests := []struct {
+		name               string
+		inputError         error
+		expectedStatusCode int
+		expectedError      error
+	}{
+		{
+			name:               "Registered sentinel",
+			inputError:         fmt.Errorf("wrapped: %w", errorGone),
+			expectedStatusCode: http.StatusGone,
+			expectedError:      errorPublicGone,
+		},
+		{
+			name:               "Registered type",
+			inputError:         fmt.Errorf("wrapped: %w", teapotError{}),
+			expectedStatusCode: http.StatusTeapot,
+			expectedError:      errorPublicTeapot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, err := mapErrorToResponse(context.TODO(), tt.inputError)
 			assert.Equal(t, tt.expectedStatusCode, statusCode)
 			assert.Equal(t, tt.expectedError, err)
 		})
 	}
 }
 
+// TestBuildAPIResponse tests the buildAPIResponse function to ensure the response carries
+// status code, body and headers, including Retry-After when the service is unavailable.
+func TestBuildAPIResponse(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		body            interface{}
+		expectedBody    string
+		expectedHeaders map[string]string
+	}{
+		{
+			name:            "Successful response",
+			status:          http.StatusOK,
+			body:            testutil.ValidUser1,
+			expectedBody:    testutil.ValidUser,
+			expectedHeaders: map[string]string{"Content-Type": "application/json"},
+		},
+		{
+			name:            "Service unavailable",
+			status:          http.StatusServiceUnavailable,
+			body:            ErrorServiceUnavailable,
+			expectedBody:    problemJSON(t, http.StatusServiceUnavailable, "service-unavailable", ""),
+			expectedHeaders: map[string]string{"Content-Type": "application/problem+json", "Retry-After": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := buildAPIResponse(context.TODO(), tt.status, tt.body)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.status, actual.StatusCode)
+				assert.JSONEq(t, tt.expectedBody, actual.Body)
+				assert.Equal(t, tt.expectedHeaders, actual.Headers)
+			}
+		})
+	}
+}
+
 // TestGetUser tests the GetUser function to ensure it correctly handles user get requests.
 // It verifies that the function returns appropriate responses for valid user fetching,
 // empty user data, and invalid JSON input. It checks if the function returns
@@ -163,7 +286,7 @@ func TestGetUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusNotFound,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorNotFound.Error()),
+				Body:       problemJSON(t, http.StatusNotFound, "user-not-found", "user does not exist: [email:e090d6e2]"),
 			},
 		},
 		{
@@ -174,14 +297,14 @@ func TestGetUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body:       problemJSON(t, http.StatusBadRequest, "no-email-query-parameter", ""),
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual, _ := GetUser(tt.request)
+			actual, _ := GetUser(context.TODO(), tt.request)
 			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
 			assert.JSONEq(t, tt.expected.Body, actual.Body)
 		})
@@ -207,7 +330,7 @@ func TestGetUsers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual, _ := GetUsers()
+			actual, _ := GetUsers(context.TODO(), events.APIGatewayProxyRequest{})
 			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
 			assert.JSONEq(t, tt.expected.Body, actual.Body)
 		})
@@ -243,7 +366,7 @@ func TestCreateUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body:       problemJSON(t, http.StatusBadRequest, "invalid-user", "failed to validate user: email is required"),
 			},
 		},
 		{
@@ -254,14 +377,15 @@ func TestCreateUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body: `{"type":"` + ProblemTypeBaseURI + `invalid-json","title":"Invalid JSON body","status":400,` +
+					`"problems":[{"pointer":"","reason":"invalid character 'b' after object key:value pair at offset 12"}]}`,
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual, _ := CreateUser(tt.request)
+			actual, _ := CreateUser(context.TODO(), tt.request)
 			t.Log(actual)
 			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
 			assert.JSONEq(t, tt.expected.Body, actual.Body)
@@ -298,7 +422,7 @@ func TestUpdateUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusNotFound,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorNotFound.Error()),
+				Body:       problemJSON(t, http.StatusNotFound, "user-not-found", "user does not exist: [email:e090d6e2]"),
 			},
 		},
 		{
@@ -309,7 +433,8 @@ func TestUpdateUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body: problemJSON(t, http.StatusBadRequest, "invalid-user",
+					"failed to validate user: Key: 'User.Email' Error:Field validation for 'Email' failed on the 'required' tag"),
 			},
 		},
 		{
@@ -320,7 +445,8 @@ func TestUpdateUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body: `{"type":"` + ProblemTypeBaseURI + `invalid-json","title":"Invalid JSON body","status":400,` +
+					`"problems":[{"pointer":"","reason":"invalid character 'b' after object key:value pair at offset 12"}]}`,
 			},
 		},
 	}
@@ -328,7 +454,7 @@ func TestUpdateUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Logf("tt: %v", tt)
-			actual, _ := UpdateUser(tt.request)
+			actual, _ := UpdateUser(context.TODO(), tt.request)
 			t.Logf("actual: %v", actual)
 			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
 			assert.JSONEq(t, tt.expected.Body, actual.Body)
@@ -366,7 +492,7 @@ func TestDeleteUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusNotFound,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorNotFound.Error()),
+				Body:       problemJSON(t, http.StatusNotFound, "user-not-found", "user does not exist: [email:e090d6e2]"),
 			},
 		},
 		{
@@ -377,7 +503,8 @@ func TestDeleteUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body: problemJSON(t, http.StatusBadRequest, "invalid-user",
+					"failed to validate user: Key: 'User.Email' Error:Field validation for 'Email' failed on the 'required' tag"),
 			},
 		},
 		{
@@ -388,7 +515,8 @@ func TestDeleteUser(t *testing.T) {
 			},
 			expected: events.APIGatewayProxyResponse{
 				StatusCode: http.StatusBadRequest,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorBadRequest.Error()),
+				Body: `{"type":"` + ProblemTypeBaseURI + `invalid-json","title":"Invalid JSON body","status":400,` +
+					`"problems":[{"pointer":"","reason":"invalid character 'b' after object key:value pair at offset 12"}]}`,
 			},
 		},
 	}
@@ -396,7 +524,7 @@ func TestDeleteUser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Logf("tt: %v", tt)
-			actual, _ := UpdateUser(tt.request)
+			actual, _ := UpdateUser(context.TODO(), tt.request)
 			t.Logf("actual: %v", actual)
 			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
 			assert.JSONEq(t, tt.expected.Body, actual.Body)
@@ -404,35 +532,217 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
-// TestUnhandledHTTPMethod tests the UnhandledHTTPMethod handler function by verifying its behavior
-// for unhandled HTTP methods. It checks if the function returns the expected API Gateway proxy
-// response with the correct status code and error message for methods not supported by the API.
-func TestUnhandledHTTPMethod(t *testing.T) {
+// TestTenantFromRequest tests the tenantFromRequest function to ensure tenant ID is taken
+// from the authorizer claim or the tenant header, and invalid, contradicting or untrusted
+// tenant IDs are rejected.
+func TestTenantFromRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		headerTrusted  bool
+		request        events.APIGatewayProxyRequest
+		expectedTenant string
+		expectedError  error
+	}{
+		{
+			name:           "No tenant",
+			request:        events.APIGatewayProxyRequest{},
+			expectedTenant: tenant.Default,
+		},
+		{
+			name:          "Tenant header",
+			headerTrusted: true,
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{"x-tenant-id": "tenant-a"},
+			},
+			expectedTenant: "tenant-a",
+		},
+		{
+			name: "Cognito claim",
+			request: events.APIGatewayProxyRequest{
+				RequestContext: events.APIGatewayProxyRequestContext{
+					Authorizer: map[string]interface{}{
+						"claims": map[string]interface{}{TenantClaim: "tenant-a"},
+					},
+				},
+			},
+			expectedTenant: "tenant-a",
+		},
+		{
+			name: "Lambda authorizer context",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{TenantHeader: "tenant-a"},
+				RequestContext: events.APIGatewayProxyRequestContext{
+					Authorizer: map[string]interface{}{TenantClaim: "tenant-a"},
+				},
+			},
+			expectedTenant: "tenant-a",
+		},
+		{
+			name: "Header contradicting claim",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{TenantHeader: "tenant-b"},
+				RequestContext: events.APIGatewayProxyRequestContext{
+					Authorizer: map[string]interface{}{TenantClaim: "tenant-a"},
+				},
+			},
+			expectedError: tenant.ErrorTenantMismatch,
+		},
+		{
+			name:          "Invalid tenant",
+			headerTrusted: true,
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{TenantHeader: "tenant#a"},
+			},
+			expectedError: tenant.ErrorInvalidTenantID,
+		},
+		{
+			name: "Tenant header not trusted",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{TenantHeader: "tenant-a"},
+			},
+			expectedError: tenant.ErrorUntrustedTenant,
+		},
+	}
+
+	previous := tenantHeaderTrusted
+	defer SetTenantHeaderTrusted(previous)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetTenantHeaderTrusted(tt.headerTrusted)
+			actualTenant, err := tenantFromRequest(tt.request)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, tt.expectedTenant, actualTenant)
+			}
+		})
+	}
+}
+
+// TestTenantIsolation tests the handlers wrapped with WithTenant to ensure a user created by
+// one tenant is not visible to other tenants. It verifies that cross-tenant reads and deletes return 404.
+func TestTenantIsolation(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+	trusted := tenantHeaderTrusted
+	defer SetTenantHeaderTrusted(trusted)
+	SetTenantHeaderTrusted(true)
+
+	headersA := map[string]string{TenantHeader: "tenant-a"}
+	headersB := map[string]string{TenantHeader: "tenant-b"}
+	notFound := problemJSON(t, http.StatusNotFound, "user-not-found", "user does not exist: [email:62e650e5]")
+
+	actual, _ := WithTenant(CreateUser)(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    headersA,
+		Body:       testutil.ValidUser,
+	})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
 	tests := []struct {
 		name     string
+		handler  func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
 		request  events.APIGatewayProxyRequest
 		expected events.APIGatewayProxyResponse
 	}{
 		{
-			name: "Unhandled HTTP method",
+			name:    "Read by another tenant",
+			handler: GetUser,
 			request: events.APIGatewayProxyRequest{
-				HTTPMethod: "PATCH",
+				HTTPMethod:            "GET",
+				Headers:               headersB,
+				QueryStringParameters: testutil.ValidQueQueryStringParameters,
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: notFound},
+		},
+		{
+			name:    "Read by default tenant",
+			handler: GetUser,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				QueryStringParameters: testutil.ValidQueQueryStringParameters,
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: notFound},
+		},
+		{
+			name:    "List by another tenant",
+			handler: GetUsers,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: "GET",
+				Headers:    headersB,
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "null"},
+		},
+		{
+			name:    "Update by another tenant",
+			handler: UpdateUser,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: "PUT",
+				Headers:    headersB,
 				Body:       testutil.ValidUser,
 			},
-			expected: events.APIGatewayProxyResponse{
-				StatusCode: http.StatusMethodNotAllowed,
-				Body:       fmt.Sprintf(`{"error":"%v"}`, ErrorMethodNotAllowed.Error()),
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: notFound},
+		},
+		{
+			name:    "Delete by another tenant",
+			handler: DeleteUser,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "DELETE",
+				Headers:               headersB,
+				QueryStringParameters: testutil.ValidQueQueryStringParameters,
 			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: notFound},
+		},
+		{
+			name:    "Read by owning tenant",
+			handler: GetUser,
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				Headers:               headersA,
+				QueryStringParameters: testutil.ValidQueQueryStringParameters,
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: testutil.ValidUser},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			t.Logf("tt: %v", tt)
-			actual, _ := UnhandledHTTPMethod(tt.request)
-			t.Logf("actual: %v", actual)
+			actual, _ := WithTenant(tt.handler)(context.TODO(), tt.request)
 			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
 			assert.JSONEq(t, tt.expected.Body, actual.Body)
 		})
 	}
 }
+
+// TestLogsRedacted tests the handlers wrapped with WithRequestLogging to ensure no raw email
+// or name of a user created, read, updated and deleted by them reaches the log output.
+func TestLogsRedacted(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+	var out bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(writer)
+
+	requests := []struct {
+		handler func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		request events.APIGatewayProxyRequest
+	}{
+		{handler: CreateUser, request: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidUser}},
+		{handler: GetUser, request: events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: testutil.ValidQueQueryStringParameters}},
+		{handler: GetUsers, request: events.APIGatewayProxyRequest{HTTPMethod: "GET"}},
+		{handler: UpdateUser, request: events.APIGatewayProxyRequest{HTTPMethod: "PUT", Body: testutil.ValidUser}},
+		{handler: DeleteUser, request: events.APIGatewayProxyRequest{HTTPMethod: "DELETE", QueryStringParameters: testutil.ValidQueQueryStringParameters}},
+		{handler: GetUser, request: events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: testutil.ValidQueQueryStringParameters}},
+	}
+	for _, r := range requests {
+		WithRequestLogging(r.handler)(context.TODO(), r.request)
+	}
+
+	assert.NotEmpty(t, out.String())
+	for _, raw := range []string{testutil.ValidUser1.Email, testutil.ValidUser1.FirstName, testutil.ValidUser1.LastName} {
+		assert.NotContains(t, out.String(), raw)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+)
+
+const (
+	// FilterParameter is the query parameter with a filter condition, it can be repeated.
+	FilterParameter = "filter"
+	// SortParameter is the query parameter with comma separated fields to sort by.
+	SortParameter = "sort"
+)
+
+// queryParameterValues returns all values of query parameter, taking repeated parameters
+// into account.
+func queryParameterValues(request events.APIGatewayProxyRequest, name string) []string {
+	if values, ok := request.MultiValueQueryStringParameters[name]; ok {
+		return values
+	}
+	if value, ok := request.QueryStringParameters[name]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+// parseFilters returns conditions of all "filter" query parameters of the request.
+func parseFilters(request events.APIGatewayProxyRequest) ([]repository.Condition, error) {
+	var conditions []repository.Condition
+	for _, expression := range queryParameterValues(request, FilterParameter) {
+		c, err := user.ParseFilter(expression)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+// listOptions returns options of listing users from "fields", "filter" and "sort" query
+// parameters of the request.
+func listOptions(request events.APIGatewayProxyRequest) (user.ListOptions, error) {
+	opts := user.ListOptions{Fields: parseFields(request.QueryStringParameters)}
+
+	conditions, err := parseFilters(request)
+	if err != nil {
+		return user.ListOptions{}, err
+	}
+	opts.Filter = conditions
+
+	if expression := request.QueryStringParameters[SortParameter]; expression != "" {
+		keys, err := user.ParseSort(expression)
+		if err != nil {
+			return user.ListOptions{}, err
+		}
+		opts.Sort = keys
+	}
+	return opts, nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListUsers tests the GetUsers handler with "filter" and "sort" query parameters to
+// ensure users are filtered by all (possibly repeated) conditions and sorted, and invalid
+// conditions or sort fields are rejected.
+func TestListUsers(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	validUser2, _ := json.Marshal(testutil.ValidUser2)
+	for _, body := range []string{testutil.ValidUser, string(validUser2)} {
+		actual, _ := CreateUser(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: body})
+		assert.Equal(t, http.StatusCreated, actual.StatusCode)
+	}
+
+	email := func(e string) string { return fmt.Sprintf(`{"email":"%v"}`, e) }
+
+	tests := []struct {
+		name     string
+		request  events.APIGatewayProxyRequest
+		expected events.APIGatewayProxyResponse
+	}{
+		{
+			name: "Sort descending",
+			request: events.APIGatewayProxyRequest{
+				QueryStringParameters: map[string]string{"fields": "email", "sort": "-firstName"},
+			},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Body:       "[" + email(testutil.ValidUser2.Email) + "," + email(testutil.ValidUser1.Email) + "]",
+			},
+		},
+		{
+			name: "Repeated filters",
+			request: events.APIGatewayProxyRequest{
+				QueryStringParameters: map[string]string{"fields": "email", "filter": "age<35"},
+				MultiValueQueryStringParameters: map[string][]string{
+					"filter": {"lastName=" + testutil.ValidUser1.LastName, "age<35"},
+				},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + email(testutil.ValidUser2.Email) + "]"},
+		},
+		{
+			name: "Prefix filter",
+			request: events.APIGatewayProxyRequest{
+				QueryStringParameters: map[string]string{"fields": "email", "filter": "firstName^=Bart"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "[" + email(testutil.ValidUser1.Email) + "]"},
+		},
+		{
+			name: "Invalid filter",
+			request: events.APIGatewayProxyRequest{
+				QueryStringParameters: map[string]string{"filter": "age>=adult"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: problemJSON(t, http.StatusBadRequest, "invalid-filter", "invalid filter: age>=adult")},
+		},
+		{
+			name: "Invalid sort",
+			request: events.APIGatewayProxyRequest{
+				QueryStringParameters: map[string]string{"sort": "password"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: problemJSON(t, http.StatusBadRequest, "invalid-sort", "invalid sort: password")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request.HTTPMethod = "GET"
+			actual, _ := GetUsers(context.TODO(), tt.request)
+			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
+			assert.JSONEq(t, tt.expected.Body, actual.Body)
+		})
+	}
+}
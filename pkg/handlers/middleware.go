@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/metrics"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+)
+
+const (
+	// ServerTimingHeader reports duration of handling requests in responses.
+	ServerTimingHeader = "Server-Timing"
+	// RequestIDHeader carries ID of the request in requests and responses.
+	RequestIDHeader = "X-Request-ID"
+	// PanicsMetric is the metric counting panics of handlers.
+	PanicsMetric = "Panics"
+)
+
+// requestIDRegex matches request IDs accepted from X-Request-ID header, safe to be logged.
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// HandlerFunc handles API Gateway proxy request.
+type HandlerFunc func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+
+// Middleware wraps handler with behavior common to requests, e.g. logging or CORS. The With
+// functions of the package (e.g. WithCORS) are middleware.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Chain returns middleware applying middleware in order, the first one outermost, so it
+// sees requests first and responses last.
+func Chain(middleware ...Middleware) Middleware {
+	return func(handler HandlerFunc) HandlerFunc {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			handler = middleware[i](handler)
+		}
+		return handler
+	}
+}
+
+// WithRequestLogging wraps handler, so its requests and status codes of their responses are
+// logged with personal data redacted.
+func WithRequestLogging(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		logging.PrintfContext(ctx, "Request: %v", request)
+		logging.PrintfContext(ctx, "HTTPMethod: %v", request.HTTPMethod)
+		logging.PrintfContext(ctx, "Path: %v", request.Path)
+		logging.PrintfContext(ctx, "Headers: %v", request.Headers)
+		logging.PrintfContext(ctx, "PathParameters: %v", request.PathParameters)
+		logging.PrintfContext(ctx, "QueryStringParameters: %v", request.QueryStringParameters)
+		logging.PrintfContext(ctx, "Body: %v", request.Body)
+
+		response, err := handler(ctx, request)
+		if err != nil {
+			logging.PrintfContext(ctx, "Error: %v", err)
+		}
+		if response != nil {
+			logging.PrintfContext(ctx, "StatusCode: %v", response.StatusCode)
+		}
+		return response, err
+	}
+}
+
+// WithTiming wraps handler, so duration of handling its requests is logged and reported in
+// Server-Timing header in milliseconds.
+func WithTiming(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		response, err := handler(ctx, request)
+		duration := time.Since(start)
+		logging.PrintfContext(ctx, "Duration: %v %v %v", request.HTTPMethod, request.Path, duration)
+
+		if response != nil {
+			if response.Headers == nil {
+				response.Headers = map[string]string{}
+			}
+			response.Headers[ServerTimingHeader] = fmt.Sprintf("app;dur=%.3f", float64(duration)/float64(time.Millisecond))
+		}
+		return response, err
+	}
+}
+
+// clientRequestID returns X-Request-ID header of the request if it is valid, else "".
+func clientRequestID(request events.APIGatewayProxyRequest) string {
+	if id := headerValue(request.Headers, RequestIDHeader); requestIDRegex.MatchString(id) {
+		return id
+	}
+	return ""
+}
+
+// requestID returns ID of the request carried by ctx, or else the API Gateway request ID,
+// or else X-Request-ID header of the request if it is valid. It is "" if there is none.
+// Clients can't choose the ID of requests served by API Gateway, so they can't spoof log
+// lines of other requests.
+func requestID(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	if id := logging.RequestID(ctx); id != "" {
+		return id
+	}
+	if id := request.RequestContext.RequestID; id != "" {
+		return id
+	}
+	return clientRequestID(request)
+}
+
+// correlationID returns ID correlating the request with its log lines: ID of the request,
+// or a random one if there is none (e.g. requests served locally).
+func correlationID(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	if id := requestID(ctx, request); id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRecovery wraps handler, so its panics are logged with stack trace, counted with
+// Panics metric and responded with 500 status code and problem details carrying correlation
+// ID as instance, instead of failing the invocation with an opaque 502 of API Gateway.
+func WithRecovery(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (response *events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			id := correlationID(ctx, request)
+			ctx = logging.WithRequestID(ctx, id)
+			logging.PrintfContext(ctx, "panic: %v\n%s", v, debug.Stack())
+			metrics.Count(PanicsMetric, nil)
+
+			p := newProblemDetails(http.StatusInternalServerError, ErrorInternalServerError)
+			p.Instance = id
+			response, err = buildAPIResponse(ctx, http.StatusInternalServerError, p)
+		}()
+		return handler(ctx, request)
+	}
+}
+
+// WithRequestID wraps handler, so ID of the request (a random one if there is none) is
+// carried by its context and prefixed to its log lines. X-Request-ID header of the request
+// is echoed in X-Request-ID header of the response, logged next to the ID if they differ,
+// or else the ID is.
+func WithRequestID(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		id := correlationID(ctx, request)
+		ctx = logging.WithRequestID(ctx, id)
+		clientID := clientRequestID(request)
+		if clientID != "" && clientID != id {
+			logging.PrintfContext(ctx, "X-Request-ID: %v", clientID)
+		}
+
+		response, err := handler(ctx, request)
+		if response != nil {
+			if response.Headers == nil {
+				response.Headers = map[string]string{}
+			}
+			response.Headers[RequestIDHeader] = cmp.Or(clientID, id)
+		}
+		return response, err
+	}
+}
+
+// WithCacheMetrics wraps handler, so counters of the cache of users increased by its requests
+// are emitted as metrics.
+func WithCacheMetrics(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		defer user.EmitCacheMetrics()
+		return handler(ctx, request)
+	}
+}
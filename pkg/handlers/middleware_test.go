@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/metrics"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+// tag returns middleware appending name to X-Trace header of requests and responses, so
+// order of middleware can be told.
+func tag(name string) Middleware {
+	return func(handler HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			headers := map[string]string{"X-Trace": request.Headers["X-Trace"] + name + ">"}
+			response, err := handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: request.HTTPMethod, Path: request.Path, Headers: headers})
+			if response != nil {
+				if response.Headers == nil {
+					response.Headers = map[string]string{}
+				}
+				response.Headers["X-Trace"] += "<" + name
+			}
+			return response, err
+		}
+	}
+}
+
+// trace is handler responding with X-Trace header of the request as body.
+func trace(_ context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: request.Headers["X-Trace"]}, nil
+}
+
+// TestChain tests the Chain function to ensure the first middleware is outermost, seeing
+// requests first and responses last, and an empty chain keeps handler as is.
+func TestChain(t *testing.T) {
+	actual, err := Chain(tag("a"), tag("b"), tag("c"))(trace)(context.TODO(), events.APIGatewayProxyRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a>b>c>", actual.Body)
+	assert.Equal(t, "<c<b<a", actual.Headers["X-Trace"])
+
+	actual, err = Chain()(trace)(context.TODO(), events.APIGatewayProxyRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", actual.Body)
+	assert.Nil(t, actual.Headers)
+}
+
+// TestRouterMiddleware tests the Router to ensure middleware used applies to all requests,
+// including unknown paths, and middleware of a route only to requests of the route, inside
+// middleware used.
+func TestRouterMiddleware(t *testing.T) {
+	r := NewRouter()
+	r.Use(tag("a"), tag("b"))
+	r.Handle("GET", "/users", trace)
+	r.Handle("GET", "/groups", trace, tag("c"))
+
+	actual, err := r.Route(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/groups"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a>b>c>", actual.Body)
+	assert.Equal(t, "<c<b<a", actual.Headers["X-Trace"])
+
+	actual, err = r.Route(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a>b>", actual.Body)
+	assert.Equal(t, "<b<a", actual.Headers["X-Trace"])
+
+	actual, err = r.Route(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/orders"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, actual.StatusCode)
+	assert.Equal(t, "<b<a", actual.Headers["X-Trace"])
+}
+
+// TestWithRequestLogging tests the WithRequestLogging wrapper to ensure requests and status
+// codes of responses are logged with personal data redacted.
+func TestWithRequestLogging(t *testing.T) {
+	var out bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(writer)
+
+	handler := WithRequestLogging(func(_ context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusCreated}, nil
+	})
+	actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/users",
+		Body:       `{"email":"john@example.com"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+	assert.Contains(t, out.String(), "HTTPMethod: POST")
+	assert.Contains(t, out.String(), "Path: /users")
+	assert.Contains(t, out.String(), "StatusCode: 201")
+	assert.NotContains(t, out.String(), "john@example.com")
+}
+
+// TestWithTiming tests the WithTiming wrapper to ensure duration of handling requests is
+// reported in Server-Timing header.
+func TestWithTiming(t *testing.T) {
+	actual, err := WithTiming(trace)(context.TODO(), events.APIGatewayProxyRequest{})
+	assert.NoError(t, err)
+	assert.Regexp(t, `^app;dur=\d+\.\d{3}$`, actual.Headers[ServerTimingHeader])
+}
+
+// TestWithRecovery tests the WithRecovery wrapper to ensure panics of handler are logged with
+// stack trace and correlation ID, counted with Panics metric and responded with 500 status
+// code and problem details carrying the correlation ID, and other responses are kept as they are.
+func TestWithRecovery(t *testing.T) {
+	var out, metricsOut bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(writer)
+	previous := metrics.SetOutput(&metricsOut)
+	defer metrics.SetOutput(previous)
+
+	handler := WithRecovery(func(_ context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		if request.HTTPMethod == "DELETE" {
+			var u *models.User
+			return buildAPIResponse(context.TODO(), http.StatusOK, *u)
+		}
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	requestID := "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"
+	actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "DELETE",
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: requestID},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, actual.StatusCode)
+	assert.Equal(t, MediaTypeProblemJSON, actual.Headers[ContentTypeHeader])
+	assert.JSONEq(t, strings.Replace(problemJSON(t, http.StatusInternalServerError, "internal-server-error", ""), "}",
+		`,"instance":"`+requestID+`"}`, 1), actual.Body)
+	assert.Contains(t, out.String(), "["+requestID+"] panic: runtime error: invalid memory address")
+	assert.Contains(t, out.String(), "runtime/debug.Stack")
+	assert.Contains(t, metricsOut.String(), `"Panics":1`)
+
+	// Requests without API Gateway request ID get a random correlation ID.
+	actual, err = handler(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "DELETE"})
+	assert.NoError(t, err)
+	var p ProblemDetails
+	if assert.NoError(t, json.Unmarshal([]byte(actual.Body), &p)) {
+		assert.Regexp(t, `^[0-9a-f]{32}$`, p.Instance)
+	}
+
+	actual, err = handler(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, actual.StatusCode)
+}
+
+// TestWithRequestID tests the WithRequestID wrapper to ensure ID of the request is the API
+// Gateway request ID, else valid X-Request-ID header, else generated, carried by context and
+// prefixed to log lines, and X-Request-ID header of requests is logged and echoed in responses.
+func TestWithRequestID(t *testing.T) {
+	var out bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(writer)
+
+	handler := WithRequestID(func(ctx context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		logging.PrintfContext(ctx, "handled")
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: logging.RequestID(ctx)}, nil
+	})
+
+	tests := []struct {
+		name           string
+		request        events.APIGatewayProxyRequest
+		expectedID     string
+		expectedHeader string
+		expectedLog    string
+	}{
+		{
+			name: "API Gateway request ID and X-Request-ID header",
+			request: events.APIGatewayProxyRequest{
+				Headers:        map[string]string{"x-request-id": "client-id-1"},
+				RequestContext: events.APIGatewayProxyRequestContext{RequestID: "gateway-id"},
+			},
+			expectedID:     "gateway-id",
+			expectedHeader: "client-id-1",
+			expectedLog:    "[gateway-id] X-Request-ID: client-id-1",
+		},
+		{
+			name: "API Gateway request ID",
+			request: events.APIGatewayProxyRequest{
+				RequestContext: events.APIGatewayProxyRequestContext{RequestID: "gateway-id"},
+			},
+			expectedID:     "gateway-id",
+			expectedHeader: "gateway-id",
+		},
+		{
+			name: "X-Request-ID header",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{RequestIDHeader: "client-id-1"},
+			},
+			expectedID:     "client-id-1",
+			expectedHeader: "client-id-1",
+		},
+		{
+			name: "Invalid X-Request-ID header",
+			request: events.APIGatewayProxyRequest{
+				Headers:        map[string]string{RequestIDHeader: "id\nforged log line"},
+				RequestContext: events.APIGatewayProxyRequestContext{RequestID: "gateway-id"},
+			},
+			expectedID:     "gateway-id",
+			expectedHeader: "gateway-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			actual, err := handler(context.TODO(), tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedID, actual.Body)
+			assert.Equal(t, tt.expectedHeader, actual.Headers[RequestIDHeader])
+			assert.Contains(t, out.String(), "["+tt.expectedID+"] handled")
+			assert.Contains(t, out.String(), tt.expectedLog)
+			assert.NotContains(t, out.String(), "forged")
+		})
+	}
+
+	// Requests without any ID get a random one.
+	actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{})
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{32}$`, actual.Body)
+	assert.Equal(t, actual.Body, actual.Headers[RequestIDHeader])
+}
+
+// TestWithTenant tests the WithTenant wrapper to ensure requests are scoped to their tenant,
+// and requests with invalid tenant IDs are rejected before reaching handler.
+func TestWithTenant(t *testing.T) {
+	previous := tenantHeaderTrusted
+	defer SetTenantHeaderTrusted(previous)
+	SetTenantHeaderTrusted(true)
+
+	handler := WithTenant(func(ctx context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: tenant.FromContext(ctx)}, nil
+	})
+
+	actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{Headers: map[string]string{TenantHeader: "tenant-a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant-a", actual.Body)
+
+	actual, err = handler(context.TODO(), events.APIGatewayProxyRequest{Headers: map[string]string{TenantHeader: "tenant#a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, actual.StatusCode)
+	assert.JSONEq(t, problemJSON(t, http.StatusBadRequest, "invalid-tenant-id", ""), actual.Body)
+
+	SetTenantHeaderTrusted(false)
+	actual, err = handler(context.TODO(), events.APIGatewayProxyRequest{Headers: map[string]string{TenantHeader: "tenant-a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, actual.StatusCode)
+	assert.JSONEq(t, problemJSON(t, http.StatusForbidden, "untrusted-tenant", ""), actual.Body)
+}
+
+// TestWithAuth tests the WithAuth wrapper to ensure requests reach handler only with
+// principal authenticated by API Gateway authorizer, unless authentication is not required.
+func TestWithAuth(t *testing.T) {
+	defer SetAuthRequired(authRequired)
+
+	tests := []struct {
+		name           string
+		required       bool
+		requestContext events.APIGatewayProxyRequestContext
+		expectedStatus int
+	}{
+		{
+			name:     "Request with claims of Cognito or JWT authorizer",
+			required: true,
+			requestContext: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "user-1"}},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "Request with principal of Lambda authorizer",
+			required: true,
+			requestContext: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{"principalId": "user-1"},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "Request with IAM caller",
+			required: true,
+			requestContext: events.APIGatewayProxyRequestContext{
+				Identity: events.APIGatewayRequestIdentity{UserArn: "arn:aws:iam::123456789012:user/john"},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "Request with claims without subject",
+			required: true,
+			requestContext: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{"claims": map[string]interface{}{TenantClaim: "tenant-a"}},
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Request without principal",
+			required:       true,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Request without principal and authentication not required",
+			required:       false,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	handler := WithAuth(func(_ context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetAuthRequired(tt.required)
+			actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{
+				HTTPMethod:     "GET",
+				Path:           "/users",
+				RequestContext: tt.requestContext,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, actual.StatusCode)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", actual.Headers[WWWAuthenticateHeader])
+				assert.JSONEq(t, problemJSON(t, http.StatusUnauthorized, "unauthorized", ""), actual.Body)
+			}
+		})
+	}
+}
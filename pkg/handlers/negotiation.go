@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+const (
+	// AcceptHeader is the request header listing media types the client accepts.
+	AcceptHeader = "Accept"
+	// ContentTypeHeader is the response header carrying media type of the body.
+	ContentTypeHeader = "Content-Type"
+)
+
+// Media types of response bodies.
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeCSV  = "text/csv"
+	MediaTypeYAML = "application/yaml"
+	MediaTypeXML  = "application/xml"
+)
+
+var (
+	ErrorNotAcceptable      = errors.New("not acceptable")
+	ErrorFailedToEncodeBody = errors.New("failed to encode body")
+	ErrorFailedToDecodeJSON = errors.New("failed to decode JSON")
+	ErrorUnsupportedValue   = errors.New("unsupported value")
+)
+
+// Encoder encodes response body decoded from JSON into representation of its media type.
+// Objects are decoded into Object, arrays into []any, numbers into json.Number, then
+// strings, booleans and nil.
+type Encoder func(v any) ([]byte, error)
+
+// encoding is media type of response bodies with its encoder.
+type encoding struct {
+	mediaType string
+	encode    Encoder
+}
+
+// encodings are negotiated in registration order, the first one is the default.
+var encodings []encoding
+
+func init() {
+	// JSON bodies are built by the handlers, so they are kept as is.
+	RegisterEncoder(MediaTypeJSON, nil)
+	RegisterEncoder(MediaTypeCSV, EncodeCSV)
+	RegisterEncoder(MediaTypeYAML, EncodeYAML)
+	RegisterEncoder(MediaTypeXML, EncodeXML)
+}
+
+// RegisterEncoder registers encoder of response bodies of media type, replacing encoder
+// registered for it before. Nil encoder keeps JSON bodies as they are. It should be called
+// during initialization (e.g. from init function).
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	for i, e := range encodings {
+		if strings.EqualFold(e.mediaType, mediaType) {
+			encodings[i].encode = encoder
+			return
+		}
+	}
+	encodings = append(encodings, encoding{mediaType: mediaType, encode: encoder})
+}
+
+// Member is a field of Object.
+type Member struct {
+	Name  string
+	Value any
+}
+
+// Object is JSON object with its fields in order of the response body.
+type Object []Member
+
+// MarshalJSON encodes object with its fields in order.
+func (o Object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(m.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// decodeJSON decodes JSON body keeping order of fields of objects.
+func decodeJSON(body string) (any, error) {
+	d := json.NewDecoder(strings.NewReader(body))
+	d.UseNumber()
+	v, err := decodeValue(d)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToDecodeJSON, err)
+	}
+	return v, nil
+}
+
+// decodeValue decodes next JSON value of decoder.
+func decodeValue(d *json.Decoder) (any, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case json.Delim('{'):
+		o := Object{}
+		for d.More() {
+			name, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, Member{Name: name.(string), Value: value})
+		}
+		// Consume closing delimiter.
+		_, err := d.Token()
+		return o, err
+	case json.Delim('['):
+		a := []any{}
+		for d.More() {
+			value, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, value)
+		}
+		_, err := d.Token()
+		return a, err
+	}
+	return t, nil
+}
+
+// mediaRange is media range of Accept header with its quality.
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept returns media ranges of Accept header. Ranges with invalid media type or
+// quality are skipped.
+func parseAccept(ctx context.Context, accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, r := range strings.Split(accept, ",") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(r)
+		if err != nil {
+			logging.PrintfContext(ctx, "invalid media range %q: %v", r, err)
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+				logging.PrintfContext(ctx, "invalid quality of media range %q", r)
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// quality returns quality of media type given by the most specific of ranges matching it,
+// e.g. "text/csv" before "text/*" before "*/*", or 0 if none does.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	specificity, q := -1, 0.0
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case r.mediaType == mainType+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		}
+		if s > specificity {
+			specificity, q = s, r.quality
+		}
+	}
+	return q
+}
+
+// negotiate returns encoding of the highest quality accepted by Accept header, preferring
+// the earlier registered on ties, and the default one if there is no Accept header. It
+// returns false if no encoding is acceptable.
+func negotiate(ctx context.Context, accept string) (encoding, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return encodings[0], true
+	}
+
+	ranges := parseAccept(ctx, accept)
+	best, bestQuality := encoding{}, 0.0
+	for _, e := range encodings {
+		if q := quality(ranges, e.mediaType); q > bestQuality {
+			best, bestQuality = e, q
+		}
+	}
+	return best, bestQuality > 0
+}
+
+// encodeBody encodes JSON body with encoder.
+func encodeBody(encode Encoder, body string) (string, error) {
+	v, err := decodeJSON(body)
+	if err != nil {
+		return "", err
+	}
+	b, err := encode(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrorFailedToEncodeBody, err)
+	}
+	return string(b), nil
+}
+
+// WithContentNegotiation wraps handler, so JSON bodies of its responses (including problem
+// details of errors) are encoded into the media type negotiated with Accept header. Requests accepting
+// none of registered media types are answered with 406 status code.
+func WithContentNegotiation(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		accept := headerValue(request.Headers, AcceptHeader)
+		e, ok := negotiate(ctx, accept)
+		if !ok {
+			logging.PrintfContext(ctx, "%v: %v", ErrorNotAcceptable, accept)
+			response, err := buildAPIResponse(ctx, http.StatusNotAcceptable, ErrorNotAcceptable)
+			addVary(response.Headers, AcceptHeader)
+			return response, err
+		}
+
+		response, err := handler(ctx, request)
+		if response == nil {
+			return response, err
+		}
+		if response.Headers == nil {
+			response.Headers = map[string]string{}
+		}
+		addVary(response.Headers, AcceptHeader)
+
+		// Keep bodies which are not JSON or have nothing to encode.
+		if e.encode == nil || !isJSON(headerValue(response.Headers, ContentTypeHeader)) ||
+			response.Body == "" || response.IsBase64Encoded {
+			return response, err
+		}
+
+		body, encodeErr := encodeBody(e.encode, response.Body)
+		if encodeErr != nil {
+			logging.PrintfContext(ctx, "%v", encodeErr)
+			// Encode error body in the negotiated media type too, if possible.
+			response, err = buildAPIResponse(ctx, http.StatusInternalServerError, ErrorInternalServerError)
+			addVary(response.Headers, AcceptHeader)
+			if body, encodeErr = encodeBody(e.encode, response.Body); encodeErr != nil {
+				return response, err
+			}
+		}
+		response.Headers[ContentTypeHeader] = e.mediaType
+		response.Body = body
+		return response, err
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNegotiate tests the negotiate function to ensure the registered media type of the
+// highest quality given by the most specific matching media range is chosen, JSON by default.
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name              string
+		accept            string
+		expectedMediaType string
+		expectedOk        bool
+	}{
+		{name: "No Accept header", accept: "", expectedMediaType: MediaTypeJSON, expectedOk: true},
+		{name: "Any", accept: "*/*", expectedMediaType: MediaTypeJSON, expectedOk: true},
+		{name: "Exact", accept: "text/csv", expectedMediaType: MediaTypeCSV, expectedOk: true},
+		{name: "Type wildcard", accept: "text/*", expectedMediaType: MediaTypeCSV, expectedOk: true},
+		{name: "Case and parameters", accept: "Application/XML; charset=utf-8", expectedMediaType: MediaTypeXML, expectedOk: true},
+		{
+			name:              "Quality",
+			accept:            "application/json;q=0.5, application/yaml;q=0.9, */*;q=0.1",
+			expectedMediaType: MediaTypeYAML,
+			expectedOk:        true,
+		},
+		{
+			name:              "Specific range overrides wildcard",
+			accept:            "application/json;q=0, */*",
+			expectedMediaType: MediaTypeCSV,
+			expectedOk:        true,
+		},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expectedMediaType: MediaTypeXML, expectedOk: true},
+		{name: "Invalid quality is skipped", accept: "text/csv;q=2, application/yaml", expectedMediaType: MediaTypeYAML, expectedOk: true},
+		{name: "Unsupported", accept: "text/html", expectedOk: false},
+		{name: "All refused", accept: "*/*;q=0", expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, ok := negotiate(context.TODO(), tt.accept)
+			assert.Equal(t, tt.expectedOk, ok)
+			assert.Equal(t, tt.expectedMediaType, actual.mediaType)
+		})
+	}
+}
+
+// TestWithContentNegotiation tests the WithContentNegotiation wrapper to ensure JSON bodies
+// of successful and error responses are encoded into the negotiated media type, responses
+// vary by Accept header and requests accepting no supported media type get 406 status code.
+func TestWithContentNegotiation(t *testing.T) {
+	handler := WithContentNegotiation(func(_ context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		if request.QueryStringParameters["email"] == "" {
+			return buildAPIResponse(context.TODO(), http.StatusNotFound, ErrorNotFound)
+		}
+		return buildAPIResponse(context.TODO(), http.StatusOK, []map[string]any{{"email": request.QueryStringParameters["email"], "age": 37}})
+	})
+	found := map[string]string{"email": "a@b.com"}
+
+	tests := []struct {
+		name                string
+		accept              string
+		query               map[string]string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Default JSON",
+			query:               found,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MediaTypeJSON,
+			expectedBody:        `[{"age":37,"email":"a@b.com"}]`,
+		},
+		{
+			name:                "CSV",
+			accept:              "text/csv",
+			query:               found,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MediaTypeCSV,
+			expectedBody:        "age,email\n37,a@b.com\n",
+		},
+		{
+			name:                "YAML",
+			accept:              "application/yaml",
+			query:               found,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: MediaTypeYAML,
+			expectedBody:        "- age: 37\n  email: a@b.com\n",
+		},
+		{
+			name:                "XML error",
+			accept:              "application/xml",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: MediaTypeXML,
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + "<response><type>" + ProblemTypeBaseURI + "not-found</type>" +
+				"<title>Resource not found</title><status>404</status></response>",
+		},
+		{
+			name:                "CSV error",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: MediaTypeCSV,
+			expectedBody:        "type,title,status\n" + ProblemTypeBaseURI + "not-found,Resource not found,404\n",
+		},
+		{
+			name:                "Not acceptable",
+			accept:              "text/html",
+			query:               found,
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: MediaTypeProblemJSON,
+			expectedBody:        problemJSON(t, http.StatusNotAcceptable, "not-acceptable", ""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{
+				Headers:               map[string]string{"accept": tt.accept},
+				QueryStringParameters: tt.query,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, actual.StatusCode)
+			assert.Equal(t, tt.expectedContentType, actual.Headers[ContentTypeHeader])
+			assert.Equal(t, tt.expectedBody, actual.Body)
+			assert.Equal(t, AcceptHeader, actual.Headers[VaryHeader])
+		})
+	}
+
+	// Bodies which are not JSON are kept as they are.
+	handler = WithContentNegotiation(func(context.Context, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Content-Type": "text/plain"}, Body: "ok"}, nil
+	})
+	actual, _ := handler(context.TODO(), events.APIGatewayProxyRequest{Headers: map[string]string{"Accept": "text/csv"}})
+	assert.Equal(t, "ok", actual.Body)
+	assert.Equal(t, "text/plain", actual.Headers[ContentTypeHeader])
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/address"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/group"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+)
+
+const (
+	// MediaTypeProblemJSON is the media type of error responses (RFC 7807).
+	MediaTypeProblemJSON = "application/problem+json"
+	// ProblemTypeBaseURI is the base URI of problem types, each documented under its name
+	// in docs/errors.md.
+	ProblemTypeBaseURI = "https://github.com/bartlomiej-jedrol/de07-aws-serverless-api/blob/main/docs/errors.md#"
+	// BlankProblemType is the problem type of errors without registered type.
+	BlankProblemType = "about:blank"
+)
+
+// ProblemDetails is body of error responses (RFC 7807), with problems of request body as
+// extension member.
+type ProblemDetails struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// problemType is type of problem of errors matching target.
+type problemType struct {
+	target error
+	name   string
+	title  string
+}
+
+// problemTypes are checked in registration order, the first matching one wins, so causes
+// (e.g. throttling) go before errors wrapping them and public errors go last. Names are
+// stable, as clients rely on them.
+var problemTypes = []problemType{
+	{repository.ErrorThrottled, "throttled", "Request throttled"},
+	{repository.ErrorProvisionedThroughputExceeded, "throughput-exceeded", "Provisioned throughput exceeded"},
+	{repository.ErrorTransientFailure, "transient-failure", "Transient failure"},
+	{tenant.ErrorInvalidTenantID, "invalid-tenant-id", "Invalid tenant ID"},
+	{tenant.ErrorTenantMismatch, "tenant-mismatch", "Tenant mismatch"},
+	{tenant.ErrorUntrustedTenant, "untrusted-tenant", "Untrusted tenant"},
+	{ErrorUnsupportedMediaType, "unsupported-media-type", "Unsupported media type"},
+	{ErrorBodyTooLarge, "body-too-large", "Request body too large"},
+	{ErrorInvalidJSON, "invalid-json", "Invalid JSON body"},
+	{ErrorNotAcceptable, "not-acceptable", "Not acceptable"},
+	{ErrorInvalidConsistentParameter, "invalid-consistent-parameter", "Invalid consistent parameter"},
+	{ErrorNoEmailQueryParameter, "no-email-query-parameter", "Missing email parameter"},
+	{ErrorNoIDQueryParameter, "no-id-query-parameter", "Missing id parameter"},
+	{ErrorNoEmailPathParameter, "no-email-path-parameter", "Missing email path parameter"},
+	{ErrorNoIDPathParameter, "no-id-path-parameter", "Missing id path parameter"},
+	{ErrorFailedToMarshalJSON, "failed-to-marshal-json", "Failed to marshal JSON"},
+	{ErrorFailedToDecodeJSON, "failed-to-decode-json", "Failed to decode JSON"},
+	{ErrorFailedToEncodeBody, "failed-to-encode-body", "Failed to encode body"},
+	{ErrorUnsupportedValue, "unsupported-value", "Unsupported value"},
+	{user.ErrorUserDoesNotExist, "user-not-found", "User not found"},
+	{user.ErrorFailedToValidateUser, "invalid-user", "Invalid user"},
+	{user.ErrorInvalidField, "invalid-field", "Invalid field"},
+	{user.ErrorInvalidFilter, "invalid-filter", "Invalid filter"},
+	{user.ErrorInvalidSort, "invalid-sort", "Invalid sort"},
+	{user.ErrorInvalidStats, "invalid-stats", "Invalid stats"},
+	{user.ErrorFailedToUnmarshalMap, "failed-to-unmarshal-user", "Failed to unmarshal user"},
+	{group.ErrorGroupDoesNotExist, "group-not-found", "Group not found"},
+	{group.ErrorMembershipDoesNotExist, "membership-not-found", "Membership not found"},
+	{group.ErrorFailedToValidateGroup, "invalid-group", "Invalid group"},
+	{group.ErrorFailedToUnmarshalMap, "failed-to-unmarshal-group", "Failed to unmarshal group"},
+	{address.ErrorAddressDoesNotExist, "address-not-found", "Address not found"},
+	{address.ErrorFailedToValidateAddress, "invalid-address", "Invalid address"},
+	{address.ErrorDefaultAddressRequired, "default-address-required", "Default address required"},
+	{address.ErrorFailedToUnmarshalMap, "failed-to-unmarshal-address", "Failed to unmarshal address"},
+	{user.ErrorFailedToGetItem, "failed-to-get-item", "Failed to get item"},
+	{user.ErrorFailedToGetItems, "failed-to-get-items", "Failed to get items"},
+	{user.ErrorFailedToPutItem, "failed-to-put-item", "Failed to put item"},
+	{user.ErrorFailedToDeleteItem, "failed-to-delete-item", "Failed to delete item"},
+	{ErrorBadRequest, "bad-request", "Bad request"},
+	{ErrorUnauthorized, "unauthorized", "Unauthorized"},
+	{ErrorForbidden, "forbidden", "Forbidden"},
+	{ErrorNotFound, "not-found", "Resource not found"},
+	{ErrorMethodNotAllowed, "method-not-allowed", "Method not allowed"},
+	{ErrorInternalServerError, "internal-server-error", "Internal server error"},
+	{ErrorServiceUnavailable, "service-unavailable", "Service unavailable"},
+}
+
+// RegisterProblemType registers problem type name, documented in docs/errors.md, and title
+// for errors matching target with errors.Is. It should be called during initialization
+// (e.g. from init function).
+func RegisterProblemType(target error, name, title string) {
+	problemTypes = append(problemTypes, problemType{target: target, name: name, title: title})
+}
+
+// lookupProblemType returns problem type of the first registered target err matches.
+func lookupProblemType(err error) (problemType, bool) {
+	for _, t := range problemTypes {
+		if errors.Is(err, t.target) {
+			return t, true
+		}
+	}
+	return problemType{}, false
+}
+
+// newProblemDetails returns problem details of err responded with status code. Details of
+// client errors tell what was wrong with personal data scrubbed, details of server errors
+// are left out not to leak internals.
+func newProblemDetails(status int, err error) ProblemDetails {
+	p := ProblemDetails{Type: BlankProblemType, Title: http.StatusText(status), Status: status}
+	t, ok := lookupProblemType(err)
+	if ok {
+		p.Type, p.Title = ProblemTypeBaseURI+t.name, t.title
+	}
+
+	var problemsErr *ProblemsError
+	switch {
+	case errors.As(err, &problemsErr):
+		p.Problems = problemsErr.Problems
+	case status >= http.StatusInternalServerError:
+	case !ok || err != t.target:
+		p.Detail = logging.Scrub(err.Error())
+	}
+	return p
+}
+
+// errorResponse builds problem details response of err mapped to HTTP status code.
+func errorResponse(ctx context.Context, err error) (*events.APIGatewayProxyResponse, error) {
+	status, _ := mapErrorToResponse(ctx, err)
+	response, buildErr := buildAPIResponse(ctx, status, newProblemDetails(status, err))
+	if response.StatusCode == http.StatusServiceUnavailable {
+		setRetryAfter(response.Headers, err)
+	}
+	return response, buildErr
+}
+
+// isProblem reports whether response has problem details body.
+func isProblem(response *events.APIGatewayProxyResponse) bool {
+	mediaType, _, _ := mime.ParseMediaType(headerValue(response.Headers, ContentTypeHeader))
+	return mediaType == MediaTypeProblemJSON
+}
+
+// WithProblemInstance wraps handler, so problem details of its error responses carry ID
+// of the request as instance.
+func WithProblemInstance(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		response, err := handler(ctx, request)
+		id := requestID(ctx, request)
+		if response == nil || id == "" || !isProblem(response) {
+			return response, err
+		}
+
+		var p ProblemDetails
+		if decodeErr := json.Unmarshal([]byte(response.Body), &p); decodeErr != nil {
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToDecodeJSON, decodeErr)
+			return response, err
+		}
+		p.Instance = id
+		body, marshalErr := json.Marshal(p)
+		if marshalErr != nil {
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToMarshalJSON, marshalErr)
+			return response, err
+		}
+		response.Body = string(body)
+		return response, err
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+	"github.com/stretchr/testify/assert"
+)
+
+// problemJSON returns problem details body of problem type name with title of the type.
+func problemJSON(t *testing.T, status int, name, detail string) string {
+	for _, pt := range problemTypes {
+		if pt.name == name {
+			b, err := json.Marshal(ProblemDetails{Type: ProblemTypeBaseURI + name, Title: pt.title, Status: status, Detail: detail})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(b)
+		}
+	}
+	t.Fatalf("unknown problem type %q", name)
+	return ""
+}
+
+// TestProblemTypesDocumented tests the problem types to ensure each has a unique name
+// documented in docs/errors.md, so type URIs resolve.
+func TestProblemTypesDocumented(t *testing.T) {
+	docs, err := os.ReadFile("../../docs/errors.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]bool{}
+	for _, pt := range problemTypes {
+		assert.False(t, names[pt.name], "duplicate problem type %q", pt.name)
+		names[pt.name] = true
+		assert.Contains(t, string(docs), "\n### "+pt.name+"\n", "undocumented problem type %q", pt.name)
+	}
+}
+
+// TestErrorResponse tests the errorResponse function to ensure errors are responded with
+// problem details of their most specific type, details of client errors with personal data
+// scrubbed, and no details of server errors.
+func TestErrorResponse(t *testing.T) {
+	throttled := &repository.RetryExhaustedError{
+		Operation:  "GetItem",
+		Attempts:   5,
+		Class:      repository.ErrorThrottled,
+		Err:        errors.New("ThrottlingException"),
+		RetryAfter: 2500 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Sentinel error",
+			err:            ErrorNoEmailQueryParameter,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"type":"` + ProblemTypeBaseURI + `no-email-query-parameter",` +
+				`"title":"Missing email parameter","status":400}`,
+		},
+		{
+			name:           "Wrapped error with personal data",
+			err:            fmt.Errorf("%w: %v", user.ErrorUserDoesNotExist, "john@example.com"),
+			expectedStatus: http.StatusNotFound,
+			expectedBody: `{"type":"` + ProblemTypeBaseURI + `user-not-found","title":"User not found",` +
+				`"status":404,"detail":"user does not exist: [email:855f96e9]"}`,
+		},
+		{
+			name:           "Problems of request body",
+			err:            &ProblemsError{Err: ErrorInvalidJSON, Problems: []Problem{{Pointer: "/age", Reason: "must be integer, got string"}}},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"type":"` + ProblemTypeBaseURI + `invalid-json","title":"Invalid JSON body","status":400,` +
+				`"problems":[{"pointer":"/age","reason":"must be integer, got string"}]}`,
+		},
+		{
+			name:           "Cause before wrapping error",
+			err:            fmt.Errorf("%w: %w", user.ErrorFailedToGetItem, throttled),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody: `{"type":"` + ProblemTypeBaseURI + `throttled",` +
+				`"title":"Request throttled","status":503}`,
+		},
+		{
+			name:           "Server error without detail",
+			err:            fmt.Errorf("%w: %v", user.ErrorFailedToPutItem, "ValidationException"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody: `{"type":"` + ProblemTypeBaseURI + `failed-to-put-item",` +
+				`"title":"Failed to put item","status":500}`,
+		},
+		{
+			name:           "Unknown error",
+			err:            errors.New("unknown error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"type":"about:blank","title":"Internal Server Error","status":500}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := errorResponse(context.TODO(), tt.err)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, actual.StatusCode)
+			assert.Equal(t, MediaTypeProblemJSON, actual.Headers[ContentTypeHeader])
+			assert.JSONEq(t, tt.expectedBody, actual.Body)
+		})
+	}
+
+	// Clients are advised to retry after the backoff the next retry would have waited.
+	actual, err := errorResponse(context.TODO(), throttled)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", actual.Headers["Retry-After"])
+}
+
+// TestWithProblemInstance tests the WithProblemInstance wrapper to ensure problem details
+// carry ID of the request as instance, while other responses are kept as they are.
+func TestWithProblemInstance(t *testing.T) {
+	handler := WithProblemInstance(func(_ context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		if request.HTTPMethod == "DELETE" {
+			return buildAPIResponse(context.TODO(), http.StatusMethodNotAllowed, ErrorMethodNotAllowed)
+		}
+		return buildAPIResponse(context.TODO(), http.StatusOK, map[string]string{"instance": "none"})
+	})
+	requestContext := events.APIGatewayProxyRequestContext{RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"}
+
+	actual, err := handler(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "DELETE", RequestContext: requestContext})
+	assert.NoError(t, err)
+	assert.JSONEq(t, strings.Replace(problemJSON(t, http.StatusMethodNotAllowed, "method-not-allowed", ""), "}",
+		`,"instance":"c6af9ac6-7b61-11e6-9a41-93e8deadbeef"}`, 1), actual.Body)
+
+	// ID of the request carried by context takes precedence.
+	ctx := logging.WithRequestID(context.TODO(), "client-id-1")
+	actual, err = handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "DELETE", RequestContext: requestContext})
+	assert.NoError(t, err)
+	assert.JSONEq(t, strings.Replace(problemJSON(t, http.StatusMethodNotAllowed, "method-not-allowed", ""), "}",
+		`,"instance":"client-id-1"}`, 1), actual.Body)
+
+	actual, err = handler(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET", RequestContext: requestContext})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"instance":"none"}`, actual.Body)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+const (
+	// AllowHeader lists methods of the resource in responses with 405 status code.
+	AllowHeader = "Allow"
+	// DeprecationHeader marks responses of deprecated endpoints.
+	DeprecationHeader = "Deprecation"
+	// LinkHeader points responses of deprecated endpoints to their successors.
+	LinkHeader = "Link"
+)
+
+// Router routes requests to handlers by method and resource path. Patterns consist of
+// literal segments and parameters in braces, e.g. "/users/{email}/addresses/{id}", whose
+// unescaped values are passed to handlers as path parameters. Middleware is applied to all
+// requests or to requests of a route.
+type Router struct {
+	routes     []*route
+	middleware []Middleware
+}
+
+// route holds handlers of a pattern by method.
+type route struct {
+	pattern  string
+	segments []string
+	handlers map[string]HandlerFunc
+}
+
+// NewRouter returns router without routes.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use applies middleware to all requests routed, including ones responded with 404 and 405
+// status codes. Middleware used first is outermost.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Handle registers handler of requests with method to resource matching pattern, wrapped
+// with middleware of the route inside middleware of all requests.
+func (r *Router) Handle(method string, pattern string, handler HandlerFunc, middleware ...Middleware) {
+	handler = Chain(middleware...)(handler)
+	pattern = "/" + strings.Trim(pattern, "/")
+	for _, rt := range r.routes {
+		if rt.pattern == pattern {
+			rt.handlers[method] = handler
+			return
+		}
+	}
+	r.routes = append(r.routes, &route{
+		pattern:  pattern,
+		segments: splitPath(pattern),
+		handlers: map[string]HandlerFunc{method: handler},
+	})
+}
+
+// Route handles request with handler of its method and resource. Resource of API Gateway
+// is used if it is a registered pattern, path of the request otherwise. Literal segments
+// take precedence over parameters, e.g. "/users/stats" over "/users/{email}". It responds
+// with 404 status code to unknown resources and 405 to unsupported methods, all within
+// middleware of all requests.
+func (r *Router) Route(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	return Chain(r.middleware...)(r.dispatch)(ctx, request)
+}
+
+// dispatch handles request with handler of its route.
+func (r *Router) dispatch(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	rt, parameters := r.match(request)
+	if rt == nil {
+		logging.PrintfContext(ctx, "no route: %v", request.Path)
+		return buildAPIResponse(ctx, http.StatusNotFound, ErrorNotFound)
+	}
+
+	handler, ok := rt.handlers[request.HTTPMethod]
+	if !ok {
+		logging.PrintfContext(ctx, "unsupported HTTP method: %v %v", request.HTTPMethod, rt.pattern)
+		response, err := buildAPIResponse(ctx, http.StatusMethodNotAllowed, ErrorMethodNotAllowed)
+		response.Headers[AllowHeader] = strings.Join(rt.methods(), ", ")
+		return response, err
+	}
+
+	// Pass parameters of the path along with ones extracted by API Gateway.
+	if len(parameters) > 0 {
+		merged := maps.Clone(request.PathParameters)
+		if merged == nil {
+			merged = map[string]string{}
+		}
+		maps.Copy(merged, parameters)
+		request.PathParameters = merged
+	}
+	return handler(ctx, request)
+}
+
+// match returns route of request and its path parameters.
+func (r *Router) match(request events.APIGatewayProxyRequest) (*route, map[string]string) {
+	for _, rt := range r.routes {
+		if rt.pattern == request.Resource {
+			return rt, nil
+		}
+	}
+
+	segments := splitPath(request.Path)
+	var best *route
+	var parameters map[string]string
+	for _, rt := range r.routes {
+		p, ok := rt.match(segments)
+		if ok && (best == nil || rt.moreSpecific(best)) {
+			best, parameters = rt, p
+		}
+	}
+	return best, parameters
+}
+
+// match returns path parameters if segments of path match the pattern.
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	parameters := map[string]string{}
+	for i, s := range rt.segments {
+		name, ok := parameterName(s)
+		if !ok {
+			if s != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		value, err := url.PathUnescape(segments[i])
+		if err != nil || value == "" {
+			return nil, false
+		}
+		parameters[name] = value
+	}
+	return parameters, true
+}
+
+// methods returns sorted methods of the route.
+func (rt *route) methods() []string {
+	var methods []string
+	for method := range rt.handlers {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+// moreSpecific reports whether the first segment the routes differ in is literal in rt.
+func (rt *route) moreSpecific(other *route) bool {
+	for i, s := range rt.segments {
+		_, parameter := parameterName(s)
+		_, otherParameter := parameterName(other.segments[i])
+		if parameter != otherParameter {
+			return !parameter
+		}
+	}
+	return false
+}
+
+// parameterName returns name of parameter segment, e.g. "email" of "{email}".
+func parameterName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// splitPath returns segments of path without leading and trailing slashes.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// Deprecated wraps handler of deprecated endpoint, so its responses point clients to
+// successor endpoint with Deprecation and Link headers.
+func Deprecated(handler HandlerFunc, successor string) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		response, err := handler(ctx, request)
+		if response != nil {
+			if response.Headers == nil {
+				response.Headers = map[string]string{}
+			}
+			response.Headers[DeprecationHeader] = "true"
+			response.Headers[LinkHeader] = "<" + successor + `>; rel="successor-version"`
+		}
+		return response, err
+	}
+}
+
+// parameter returns path parameter of name, or query parameter of the deprecated
+// query-parameter form of endpoints.
+func parameter(request events.APIGatewayProxyRequest, name string) string {
+	if value := request.PathParameters[name]; value != "" {
+		return value
+	}
+	return request.QueryStringParameters[name]
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// echo returns handler responding with name and path parameters of the request.
+func echo(name string) HandlerFunc {
+	return func(_ context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       fmt.Sprintf("%v %v", name, request.PathParameters),
+		}, nil
+	}
+}
+
+// TestRouter tests the Router to ensure requests are routed by method and path with
+// unescaped path parameters, literal segments take precedence over parameters, registered
+// API Gateway resources are used as is, and unknown paths and methods are rejected with
+// 404 and 405 status codes.
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	r.Handle("GET", "/users", echo("GetUsers"))
+	r.Handle("POST", "/users", echo("CreateUser"))
+	r.Handle("GET", "/users/stats", echo("GetUserStats"))
+	r.Handle("GET", "/users/{email}", echo("GetUser"))
+	r.Handle("DELETE", "/users/{email}", echo("DeleteUser"))
+	r.Handle("GET", "/users/{email}/addresses/{id}", echo("GetAddress"))
+
+	tests := []struct {
+		name     string
+		request  events.APIGatewayProxyRequest
+		expected events.APIGatewayProxyResponse
+	}{
+		{
+			name:     "Collection",
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/"},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "GetUsers map[]"},
+		},
+		{
+			name:     "Resource",
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/users/john%40example.com"},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "DeleteUser map[email:john@example.com]"},
+		},
+		{
+			name:     "Literal before parameter",
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/stats"},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "GetUserStats map[]"},
+		},
+		{
+			name:     "Nested resource",
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/john@example.com/addresses/home"},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "GetAddress map[email:john@example.com id:home]"},
+		},
+		{
+			name: "API Gateway resource",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "GET",
+				Resource:       "/users/{email}",
+				Path:           "/prod/users/john@example.com",
+				PathParameters: map[string]string{"email": "john@example.com"},
+			},
+			expected: events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: "GetUser map[email:john@example.com]"},
+		},
+		{
+			name:    "Unknown path",
+			request: events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/john@example.com/orders"},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Headers:    map[string]string{"Content-Type": "application/problem+json"},
+				Body:       problemJSON(t, http.StatusNotFound, "not-found", ""),
+			},
+		},
+		{
+			name:    "Unsupported method",
+			request: events.APIGatewayProxyRequest{HTTPMethod: "PATCH", Path: "/users/john@example.com"},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusMethodNotAllowed,
+				Headers:    map[string]string{"Content-Type": "application/problem+json", AllowHeader: "DELETE, GET"},
+				Body:       problemJSON(t, http.StatusMethodNotAllowed, "method-not-allowed", ""),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := r.Route(context.TODO(), tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, *actual)
+		})
+	}
+}
+
+// TestDeprecated tests that responses of deprecated query-parameter endpoints keep their
+// bodies and point to the successor resource, which serves the same user.
+func TestDeprecated(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	actual, _ := CreateUser(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidUser})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
+	r := NewRouter()
+	r.Handle("GET", "/users", Deprecated(GetUser, "/users/{email}"))
+	r.Handle("GET", "/users/{email}", GetUser)
+
+	legacy, _ := r.Route(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/users",
+		QueryStringParameters: testutil.ValidQueQueryStringParameters,
+	})
+	assert.Equal(t, http.StatusOK, legacy.StatusCode)
+	assert.Equal(t, testutil.ValidUser, legacy.Body)
+	assert.Equal(t, "true", legacy.Headers[DeprecationHeader])
+	assert.Equal(t, `</users/{email}>; rel="successor-version"`, legacy.Headers[LinkHeader])
+
+	successor, _ := r.Route(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/users/" + testutil.ValidUser1.Email,
+	})
+	assert.Equal(t, http.StatusOK, successor.StatusCode)
+	assert.Equal(t, testutil.ValidUser, successor.Body)
+	assert.NotContains(t, successor.Headers, DeprecationHeader)
+}
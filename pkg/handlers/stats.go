@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/user"
+)
+
+func init() {
+	RegisterError(user.ErrorInvalidStats, http.StatusBadRequest, ErrorBadRequest)
+}
+
+// statsOptions returns options of statistics of users from "filter", "groupBy", "bucket"
+// and "aggregate" query parameters of the request.
+func statsOptions(request events.APIGatewayProxyRequest) (user.StatsOptions, error) {
+	conditions, err := parseFilters(request)
+	if err != nil {
+		return user.StatsOptions{}, err
+	}
+	opts := user.StatsOptions{Filter: conditions, GroupBy: request.QueryStringParameters["groupBy"]}
+
+	if bucket, ok := request.QueryStringParameters["bucket"]; ok {
+		opts.AgeBucket, err = strconv.Atoi(bucket)
+		if err != nil || opts.AgeBucket <= 0 {
+			return user.StatsOptions{}, fmt.Errorf("%w: bucket %v", user.ErrorInvalidStats, bucket)
+		}
+	}
+
+	switch aggregate := request.QueryStringParameters["aggregate"]; aggregate {
+	case "":
+	case "age":
+		opts.Age = true
+	default:
+		return user.StatsOptions{}, fmt.Errorf("%w: aggregate %v", user.ErrorInvalidStats, aggregate)
+	}
+	return opts, nil
+}
+
+// GetUserStats gets statistics of users from DynamoDB table and responds.
+func GetUserStats(
+	ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	// Extract filter, grouping and aggregates from request.
+	opts, err := statsOptions(request)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Fetch statistics.
+	stats, err := user.FetchStats(ctx, opts)
+	if err != nil {
+		return errorResponse(ctx, err)
+	}
+
+	// Send successful response.
+	return buildAPIResponse(ctx, http.StatusOK, stats)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetUserStats tests the GetUserStats handler to ensure statistics are computed for
+// the filter, grouping and aggregates of the query parameters, and invalid parameters
+// are rejected.
+func TestGetUserStats(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	actual, _ := CreateUser(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: testutil.ValidUser})
+	assert.Equal(t, http.StatusCreated, actual.StatusCode)
+
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		expected   events.APIGatewayProxyResponse
+	}{
+		{
+			name:       "Count",
+			parameters: nil,
+			expected:   events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"count":1}`},
+		},
+		{
+			name:       "Filtered count",
+			parameters: map[string]string{"filter": "age<18"},
+			expected:   events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"count":0}`},
+		},
+		{
+			name:       "Grouped and aggregated",
+			parameters: map[string]string{"groupBy": "age", "bucket": "20", "aggregate": "age"},
+			expected: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"count":1,"groups":{"20-39":1},"age":{"min":37,"max":37,"mean":37}}`,
+			},
+		},
+		{
+			name:       "Invalid bucket",
+			parameters: map[string]string{"groupBy": "age", "bucket": "0"},
+			expected:   events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: problemJSON(t, http.StatusBadRequest, "invalid-stats", "invalid stats: bucket 0")},
+		},
+		{
+			name:       "Invalid aggregate",
+			parameters: map[string]string{"aggregate": "email"},
+			expected:   events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: problemJSON(t, http.StatusBadRequest, "invalid-stats", "invalid stats: aggregate email")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, _ := GetUserStats(context.TODO(), events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				QueryStringParameters: tt.parameters,
+			})
+			assert.Equal(t, tt.expected.StatusCode, actual.StatusCode)
+			assert.JSONEq(t, tt.expected.Body, actual.Body)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+)
+
+const (
+	// TenantHeader is the header carrying tenant ID of requests without authorizer claim.
+	TenantHeader = "X-Tenant-ID"
+	// TenantClaim is the authorizer claim (or Lambda authorizer context key) carrying tenant ID.
+	TenantClaim = "custom:tenantId"
+)
+
+// tenantHeaderTrusted makes requests without authorizer claim scoped to tenant of the tenant
+// header. It is enabled with TENANT_HEADER_TRUSTED=true, e.g. behind a proxy setting the
+// header, as otherwise any client could pick its tenant.
+var tenantHeaderTrusted = tenantHeaderTrustedFromEnv()
+
+// SetTenantHeaderTrusted replaces whether the tenant header is trusted without authorizer claim.
+func SetTenantHeaderTrusted(trusted bool) {
+	tenantHeaderTrusted = trusted
+}
+
+// tenantHeaderTrustedFromEnv returns TENANT_HEADER_TRUSTED environment variable falling back
+// to false.
+func tenantHeaderTrustedFromEnv() bool {
+	trusted, _ := strconv.ParseBool(os.Getenv("TENANT_HEADER_TRUSTED"))
+	return trusted
+}
+
+// tenantFromRequest extracts tenant ID from the authorizer claim or from the tenant header.
+// The claim takes precedence, a header contradicting it is rejected, and so is a header
+// without claim unless the header is trusted.
+func tenantFromRequest(request events.APIGatewayProxyRequest) (string, error) {
+	claim := tenantClaim(request.RequestContext.Authorizer)
+	header := tenantHeader(request.Headers)
+
+	if claim != "" && header != "" && claim != header {
+		return "", tenant.ErrorTenantMismatch
+	}
+	if claim == "" && header != "" && !tenantHeaderTrusted {
+		return "", tenant.ErrorUntrustedTenant
+	}
+
+	id := claim
+	if id == "" {
+		id = header
+	}
+	if id == tenant.Default {
+		return tenant.Default, nil
+	}
+	if err := tenant.Validate(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// tenantClaim returns tenant ID from Cognito user pool claims or Lambda authorizer context.
+func tenantClaim(authorizer map[string]interface{}) string {
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if id, ok := claims[TenantClaim].(string); ok {
+			return id
+		}
+	}
+	if id, ok := authorizer[TenantClaim].(string); ok {
+		return id
+	}
+	return ""
+}
+
+// tenantHeader returns tenant ID from headers, header names are case-insensitive.
+func tenantHeader(headers map[string]string) string {
+	for name, value := range headers {
+		if strings.EqualFold(name, TenantHeader) {
+			return value
+		}
+	}
+	return ""
+}
+
+// withTenant returns a copy of ctx carrying tenant ID of the request.
+func withTenant(ctx context.Context, request events.APIGatewayProxyRequest) (context.Context, error) {
+	id, err := tenantFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return tenant.WithID(ctx, id), nil
+}
+
+// WithTenant wraps handler, so its requests are scoped to tenant of the request, and requests
+// with invalid, contradicting or untrusted tenant IDs are rejected.
+func WithTenant(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		tenantCtx, err := withTenant(ctx, request)
+		if err != nil {
+			return errorResponse(ctx, err)
+		}
+		return handler(tenantCtx, request)
+	}
+}
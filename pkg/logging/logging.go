@@ -0,0 +1,265 @@
+// Logging implements logging with personal data and credentials redacted. Emails are
+// replaced with their hashes, so log lines of the same user can still be correlated,
+// names, tokens and values of sensitive headers are replaced with a placeholder.
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Redacted replaces redacted values.
+const Redacted = "[REDACTED]"
+
+var (
+	// HashedFields are names of fields, attributes and parameters whose values are hashed.
+	HashedFields = []string{"email"}
+	// RedactedFields are names of fields, attributes and parameters whose values are redacted,
+	// e.g. names and postal addresses.
+	RedactedFields = []string{
+		"firstName", "lastName", "line1", "line2", "city", "region", "postalCode", "country",
+		"password", "token", "accessToken", "refreshToken",
+	}
+	// DefaultRedactedHeaders are headers whose values are redacted, more headers can be
+	// added with comma separated LOG_REDACTED_HEADERS environment variable.
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+	emailRegex  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bearerRegex = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/\-]+=*`)
+
+	// hashed and redacted hold lower case names of hashed and redacted keys.
+	hashed   map[string]bool
+	redacted map[string]bool
+	// jsonRegex and parameterRegex match sensitive fields in JSON ("firstName": "John")
+	// and parameters (lastName=Smith) with the name in group 1, separator in group 2 and
+	// value in group 3.
+	jsonRegex      *regexp.Regexp
+	parameterRegex *regexp.Regexp
+)
+
+func init() {
+	// Load redacted headers.
+	headers := DefaultRedactedHeaders
+	if v := os.Getenv("LOG_REDACTED_HEADERS"); v != "" {
+		headers = append(headers, strings.Split(v, ",")...)
+	}
+	SetRedactedHeaders(headers...)
+}
+
+// SetRedactedHeaders replaces headers whose values are redacted.
+func SetRedactedHeaders(headers ...string) {
+	hashed = map[string]bool{}
+	redacted = map[string]bool{}
+	var names []string
+	for _, f := range HashedFields {
+		hashed[strings.ToLower(f)] = true
+		names = append(names, regexp.QuoteMeta(f))
+	}
+	for _, f := range RedactedFields {
+		redacted[strings.ToLower(f)] = true
+		names = append(names, regexp.QuoteMeta(f))
+	}
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			redacted[strings.ToLower(h)] = true
+		}
+	}
+	fields := strings.Join(names, "|")
+	jsonRegex = regexp.MustCompile(`(?i)("(?:` + fields + `)")(\s*:\s*")((?:[^"\\]|\\.)*)`)
+	parameterRegex = regexp.MustCompile(`(?i)\b(` + fields + `)(=)([^&,\s"]*)`)
+}
+
+// Printf logs message like log.Printf with sensitive data of arguments redacted.
+func Printf(format string, v ...any) {
+	log.Output(2, Sprintf(format, v...))
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying ID of the request, prefixed to messages logged
+// with PrintfContext.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns ID of the request carried by ctx or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// PrintfContext logs message like Printf prefixed with ID of the request carried by ctx, so
+// log lines of a request can be correlated.
+func PrintfContext(ctx context.Context, format string, v ...any) {
+	message := Sprintf(format, v...)
+	if id := RequestID(ctx); id != "" {
+		message = "[" + id + "] " + message
+	}
+	log.Output(2, message)
+}
+
+// Fatalf logs message like Printf and exits like log.Fatalf.
+func Fatalf(format string, v ...any) {
+	log.Output(2, Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// Sprintf formats message like fmt.Sprintf with sensitive data redacted. Sensitive fields
+// of structs and keys of maps (e.g. users, items and headers) are redacted first, then
+// emails, tokens and sensitive fields left in the formatted message.
+func Sprintf(format string, v ...any) string {
+	args := make([]any, len(v))
+	for i, arg := range v {
+		args[i] = Redact(arg)
+	}
+	return Scrub(fmt.Sprintf(format, args...))
+}
+
+// Scrub returns s with emails hashed, and tokens and values of sensitive fields redacted.
+func Scrub(s string) string {
+	for _, r := range []*regexp.Regexp{jsonRegex, parameterRegex} {
+		s = r.ReplaceAllStringFunc(s, func(m string) string {
+			g := r.FindStringSubmatch(m)
+			return g[1] + g[2] + redact(strings.Trim(g[1], `"`), g[3])
+		})
+	}
+	s = bearerRegex.ReplaceAllString(s, "$1 "+Redacted)
+	return emailRegex.ReplaceAllStringFunc(s, Hash)
+}
+
+// Hash returns short hash of email identifying it in logs without revealing it.
+func Hash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "[email:" + hex.EncodeToString(sum[:4]) + "]"
+}
+
+// Redact returns copy of v with sensitive fields of structs and values of sensitive keys of
+// maps redacted, recursively. Other values are returned as is.
+func Redact(v any) any {
+	if v == nil {
+		return nil
+	}
+	switch v.(type) {
+	case error, fmt.Stringer:
+		// Formatted message is scrubbed.
+		return v
+	}
+	return redactValue(reflect.ValueOf(v)).Interface()
+}
+
+// redactValue returns copy of v with sensitive values redacted.
+func redactValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(redactValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(redactValue(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := c.Field(i)
+			if !f.CanSet() {
+				continue
+			}
+			f.Set(redactKey(fieldName(v.Type().Field(i)), f))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			value := it.Value()
+			if it.Key().Kind() == reflect.String {
+				value = redactKey(it.Key().String(), value)
+			} else {
+				value = redactValue(value)
+			}
+			c.SetMapIndex(it.Key(), value)
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return c
+	}
+	return v
+}
+
+// redactKey returns copy of v with value of key redacted if key is sensitive.
+func redactKey(key string, v reflect.Value) reflect.Value {
+	lower := strings.ToLower(key)
+	if !hashed[lower] && !redacted[lower] {
+		return redactValue(v)
+	}
+
+	// Replace strings, string attributes and string slices (multi-value headers).
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(redact(key, v.String())).Convert(t)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		c := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).SetString(redact(key, v.Index(i).String()))
+		}
+		return c
+	case t.Kind() == reflect.Interface && !v.IsNil():
+		if s, ok := v.Interface().(*types.AttributeValueMemberS); ok {
+			c := reflect.New(t).Elem()
+			c.Set(reflect.ValueOf(&types.AttributeValueMemberS{Value: redact(key, s.Value)}))
+			return c
+		}
+		if e := v.Elem(); e.Kind() == reflect.String {
+			c := reflect.New(t).Elem()
+			c.Set(reflect.ValueOf(redact(key, e.String())).Convert(e.Type()))
+			return c
+		}
+	}
+	return redactValue(v)
+}
+
+// redact returns hash of value of hashed key and placeholder of value of redacted key.
+func redact(key string, value string) string {
+	if value == "" {
+		return value
+	}
+	if hashed[strings.ToLower(key)] {
+		return Hash(value)
+	}
+	return Redacted
+}
+
+// fieldName returns name of struct field in JSON, or its Go name.
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
@@ -0,0 +1,93 @@
+// Metrics implements metrics in CloudWatch embedded metric format. Metrics are written as
+// JSON lines to standard output, so CloudWatch Logs extracts them from the log of the Lambda
+// function without calling CloudWatch API.
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+// DefaultNamespace is the default CloudWatch namespace of metrics.
+const DefaultNamespace = "de07-aws-serverless-api"
+
+// UnitCount is the unit of metrics counting events.
+const UnitCount = "Count"
+
+var (
+	mu        sync.Mutex
+	output    io.Writer = os.Stdout
+	namespace           = namespaceFromEnv()
+)
+
+// namespaceFromEnv returns namespace of METRICS_NAMESPACE environment variable falling
+// back to the default.
+func namespaceFromEnv() string {
+	if v := os.Getenv("METRICS_NAMESPACE"); v != "" {
+		return v
+	}
+	return DefaultNamespace
+}
+
+// SetOutput replaces writer of metrics and returns the previous one.
+func SetOutput(w io.Writer) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	previous := output
+	output = w
+	return previous
+}
+
+// directive is the metadata telling CloudWatch which members of the line are metrics.
+type directive struct {
+	Namespace  string     `json:"Namespace"`
+	Dimensions [][]string `json:"Dimensions"`
+	Metrics    []metric   `json:"Metrics"`
+}
+
+type metric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// Emit writes metric of name with value in unit, e.g. UnitCount, and dimensions.
+func Emit(name string, value float64, unit string, dimensions map[string]string) {
+	keys := make([]string, 0, len(dimensions))
+	line := map[string]any{name: value}
+	for k, v := range dimensions {
+		keys = append(keys, k)
+		line[k] = v
+	}
+	slices.Sort(keys)
+
+	line["_aws"] = map[string]any{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []directive{{
+			Namespace:  namespace,
+			Dimensions: [][]string{keys},
+			Metrics:    []metric{{Name: name, Unit: unit}},
+		}},
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		logging.Printf("failed to marshal metric %v: %v", name, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := output.Write(append(b, '\n')); err != nil {
+		logging.Printf("failed to write metric %v: %v", name, err)
+	}
+}
+
+// Count writes metric of name counting one event with dimensions.
+func Count(name string, dimensions map[string]string) {
+	Emit(name, 1, UnitCount, dimensions)
+}
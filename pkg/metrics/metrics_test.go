@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEmit tests the Emit function to ensure metrics are written as JSON lines in embedded
+// metric format, with value and dimensions as members and their names in the directive.
+func TestEmit(t *testing.T) {
+	var out bytes.Buffer
+	previous := SetOutput(&out)
+	defer SetOutput(previous)
+
+	tests := []struct {
+		name               string
+		emit               func()
+		expectedMembers    map[string]any
+		expectedDimensions []any
+		expectedMetric     map[string]any
+	}{
+		{
+			name:               "Count without dimensions",
+			emit:               func() { Count("Panics", nil) },
+			expectedMembers:    map[string]any{"Panics": 1.0},
+			expectedDimensions: []any{[]any{}},
+			expectedMetric:     map[string]any{"Name": "Panics", "Unit": UnitCount},
+		},
+		{
+			name:               "Metric with dimensions",
+			emit:               func() { Emit("Latency", 12.5, "Milliseconds", map[string]string{"Route": "/users", "Method": "GET"}) },
+			expectedMembers:    map[string]any{"Latency": 12.5, "Method": "GET", "Route": "/users"},
+			expectedDimensions: []any{[]any{"Method", "Route"}},
+			expectedMetric:     map[string]any{"Name": "Latency", "Unit": "Milliseconds"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			tt.emit()
+			assert.Equal(t, byte('\n'), out.Bytes()[out.Len()-1])
+
+			var line map[string]any
+			if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.expectedMembers {
+				assert.Equal(t, v, line[k])
+			}
+
+			metadata := line["_aws"].(map[string]any)
+			assert.NotZero(t, metadata["Timestamp"])
+			directives := metadata["CloudWatchMetrics"].([]any)
+			if assert.Len(t, directives, 1) {
+				directive := directives[0].(map[string]any)
+				assert.Equal(t, DefaultNamespace, directive["Namespace"])
+				assert.Equal(t, tt.expectedDimensions, directive["Dimensions"])
+				assert.Equal(t, []any{tt.expectedMetric}, directive["Metrics"])
+			}
+		})
+	}
+}
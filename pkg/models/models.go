@@ -1,6 +1,10 @@
 package models
 
-import "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
 
 type User struct {
 	Email     string `json:"email" validate:"required"`
@@ -9,7 +13,40 @@ type User struct {
 	Age       int    `json:"age"`
 }
 
+type Group struct {
+	ID          string `json:"id" validate:"required"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Membership struct {
+	GroupID string `json:"groupId" validate:"required"`
+	Email   string `json:"email" validate:"required"`
+}
+
+type Address struct {
+	ID         string `json:"id" validate:"required"`
+	Type       string `json:"type" validate:"required,oneof=home work billing"`
+	Default    bool   `json:"default"`
+	Line1      string `json:"line1" validate:"required"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city" validate:"required"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country" validate:"required,iso3166_1_alpha2"`
+}
+
+// DynamoDBAPI is the subset of DynamoDB client operations used by the API.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
 type TableBasics struct {
-	DynamoDbClient *dynamodb.Client
+	DynamoDbClient DynamoDBAPI
 	TableName      string
 }
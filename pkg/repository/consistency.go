@@ -0,0 +1,17 @@
+package repository
+
+import "context"
+
+type consistentReadKey struct{}
+
+// WithConsistentRead returns a copy of ctx instructing repositories to read with strong
+// consistency, so writes acknowledged before the read are visible to it.
+func WithConsistentRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, consistentReadKey{}, true)
+}
+
+// ConsistentRead reports whether ctx instructs repositories to read with strong consistency.
+func ConsistentRead(ctx context.Context) bool {
+	consistent, _ := ctx.Value(consistentReadKey{}).(bool)
+	return consistent
+}
@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/encryption"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+)
+
+var (
+	defaultTable      = models.TableBasics{TableName: "de07-api"}
+	defaultRepository Repository
+
+	ErrorFailedToLoadAWSConfig        = errors.New("failed to load AWS config")
+	ErrorFailedToCreateDynamoDBClient = errors.New("failed to create DynamoDB client")
+	ErrorFailedToLoadEncryptionKeys   = errors.New("failed to load encryption keys")
+
+	// DefaultEncryptedAttributes are PII attributes of users encrypted if encryption is enabled.
+	DefaultEncryptedAttributes = []string{"firstName", "lastName", "age"}
+)
+
+func init() {
+	// Load AWS config (~/.aws/config).
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		logging.Fatalf("%v: %v", ErrorFailedToLoadAWSConfig, err)
+	}
+
+	// Create DynamoDB client. Retries are handled by withRetry, so the SDK ones are disabled.
+	// Endpoint and table can be overridden, e.g. to run against DynamoDB Local.
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if table := os.Getenv("DYNAMODB_TABLE"); table != "" {
+		defaultTable.TableName = table
+	}
+	defaultTable.DynamoDbClient = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		o.RetryMaxAttempts = 1
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	if defaultTable.DynamoDbClient == nil {
+		logging.Fatalf("%v: %v", ErrorFailedToCreateDynamoDBClient, err)
+	}
+	defaultRepository = NewDynamoDB(defaultTable)
+
+	// Encrypt PII attributes if master keys are configured.
+	if path := os.Getenv("ENCRYPTION_KEY_FILE"); path != "" {
+		provider, err := encryption.LoadLocalKeyProvider(path)
+		if err != nil {
+			logging.Fatalf("%v: %v", ErrorFailedToLoadEncryptionKeys, err)
+		}
+		defaultRepository = NewEncrypted(defaultRepository, provider, encryptedAttributesFromEnv()...)
+	}
+}
+
+// encryptedAttributesFromEnv returns comma separated attributes to encrypt from environment
+// variable falling back to DefaultEncryptedAttributes.
+func encryptedAttributesFromEnv() []string {
+	value := os.Getenv("ENCRYPTED_ATTRIBUTES")
+	if value == "" {
+		return DefaultEncryptedAttributes
+	}
+	var attributes []string
+	for _, attribute := range strings.Split(value, ",") {
+		attributes = append(attributes, strings.TrimSpace(attribute))
+	}
+	return attributes
+}
+
+// Default returns repository shared by all entities, by default stored in DynamoDB table.
+func Default() Repository {
+	return defaultRepository
+}
+
+// SetDefault replaces repository shared by all entities (e.g. with in-memory one in tests)
+// and returns the previous one.
+func SetDefault(r Repository) Repository {
+	previous := defaultRepository
+	defaultRepository = r
+	return previous
+}
+
+// dynamoDBRepository stores items in DynamoDB table.
+type dynamoDBRepository struct {
+	table models.TableBasics
+}
+
+// NewDynamoDB returns repository storing items in DynamoDB table.
+func NewDynamoDB(table models.TableBasics) Repository {
+	return &dynamoDBRepository{table: table}
+}
+
+func (r *dynamoDBRepository) Get(ctx context.Context, key Key, opts ...ReadOption) (Item, error) {
+	if err := key.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToGetItem, err)
+	}
+
+	o := readOptions(opts)
+	names := map[string]string{}
+	input := dynamodb.GetItemInput{
+		Key:                  key.Attributes(),
+		TableName:            aws.String(r.table.TableName),
+		ProjectionExpression: o.projectionExpression(names),
+		ConsistentRead:       aws.Bool(ConsistentRead(ctx)),
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+
+	var output *dynamodb.GetItemOutput
+	err := withRetry(ctx, "GetItem", func() (err error) {
+		output, err = r.table.DynamoDbClient.GetItem(ctx, &input)
+		return err
+	})
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorFailedToGetItem, err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToGetItem, err)
+	}
+
+	if output.Item == nil {
+		return nil, fmt.Errorf("%w: %v", ErrorItemNotFound, key)
+	}
+	return output.Item, nil
+}
+
+func (r *dynamoDBRepository) Put(ctx context.Context, item Item) error {
+	if err := KeyOf(item).validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrorFailedToPutItem, err)
+	}
+
+	input := dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(r.table.TableName),
+	}
+
+	err := withRetry(ctx, "PutItem", func() error {
+		_, err := r.table.DynamoDbClient.PutItem(ctx, &input)
+		return err
+	})
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorFailedToPutItem, err)
+		return fmt.Errorf("%w: %w", ErrorFailedToPutItem, err)
+	}
+	return nil
+}
+
+func (r *dynamoDBRepository) Delete(ctx context.Context, key Key) (Item, error) {
+	if err := key.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToDeleteItem, err)
+	}
+
+	input := dynamodb.DeleteItemInput{
+		Key:          key.Attributes(),
+		TableName:    aws.String(r.table.TableName),
+		ReturnValues: types.ReturnValueAllOld,
+	}
+
+	var output *dynamodb.DeleteItemOutput
+	err := withRetry(ctx, "DeleteItem", func() (err error) {
+		output, err = r.table.DynamoDbClient.DeleteItem(ctx, &input)
+		return err
+	})
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorFailedToDeleteItem, err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToDeleteItem, err)
+	}
+
+	// Item did not exist if there are no old attributes.
+	if output.Attributes == nil {
+		return nil, fmt.Errorf("%w: %v", ErrorItemNotFound, key)
+	}
+	return output.Attributes, nil
+}
+
+func (r *dynamoDBRepository) Transact(ctx context.Context, writes ...Write) error {
+	input := dynamodb.TransactWriteItemsInput{}
+	for _, w := range writes {
+		if err := w.key().validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrorFailedToWriteItems, err)
+		}
+		if w.Put != nil {
+			input.TransactItems = append(input.TransactItems, types.TransactWriteItem{
+				Put: &types.Put{Item: w.Put, TableName: aws.String(r.table.TableName)},
+			})
+		} else {
+			input.TransactItems = append(input.TransactItems, types.TransactWriteItem{
+				Delete: &types.Delete{Key: w.Delete.Attributes(), TableName: aws.String(r.table.TableName)},
+			})
+		}
+	}
+
+	err := withRetry(ctx, "TransactWriteItems", func() error {
+		_, err := r.table.DynamoDbClient.TransactWriteItems(ctx, &input)
+		return err
+	})
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v", ErrorFailedToWriteItems, err)
+		return fmt.Errorf("%w: %w", ErrorFailedToWriteItems, err)
+	}
+	return nil
+}
+
+func (r *dynamoDBRepository) Query(ctx context.Context, pk string, skPrefix string, opts ...ReadOption) ([]Item, error) {
+	o := readOptions(opts)
+	names := map[string]string{
+		"#pk": PartitionKeyAttribute,
+		"#sk": SortKeyAttribute,
+	}
+	values := Item{
+		":pk": &types.AttributeValueMemberS{Value: pk},
+		":sk": &types.AttributeValueMemberS{Value: skPrefix},
+	}
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(r.table.TableName),
+		KeyConditionExpression:    aws.String("#pk = :pk AND begins_with(#sk, :sk)"),
+		ProjectionExpression:      o.projectionExpression(names),
+		ConsistentRead:            aws.Bool(ConsistentRead(ctx)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	if filter := o.filterExpression(names, values); filter != "" {
+		input.FilterExpression = aws.String(filter)
+	}
+
+	// Read all pages of the partition.
+	var items []Item
+	for {
+		var output *dynamodb.QueryOutput
+		err := withRetry(ctx, "Query", func() (err error) {
+			output, err = r.table.DynamoDbClient.Query(ctx, &input)
+			return err
+		})
+		if err != nil {
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToGetItems, err)
+			return nil, fmt.Errorf("%w: %w", ErrorFailedToGetItems, err)
+		}
+		items = append(items, output.Items...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func (r *dynamoDBRepository) Scan(ctx context.Context, entity EntityType, opts ...ReadOption) ([]Item, error) {
+	o := readOptions(opts)
+	filter, names, values := entityFilter(ctx, entity)
+	if conditions := o.filterExpression(names, values); conditions != "" {
+		filter += " AND " + conditions
+	}
+	input := dynamodb.ScanInput{
+		TableName:                 aws.String(r.table.TableName),
+		FilterExpression:          aws.String(filter),
+		ProjectionExpression:      o.projectionExpression(names),
+		ConsistentRead:            aws.Bool(ConsistentRead(ctx)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	// Read all pages, filtered pages of a shared table may be empty.
+	var items []Item
+	for {
+		var output *dynamodb.ScanOutput
+		err := withRetry(ctx, "Scan", func() (err error) {
+			output, err = r.table.DynamoDbClient.Scan(ctx, &input)
+			return err
+		})
+		if err != nil {
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToGetItems, err)
+			return nil, fmt.Errorf("%w: %w", ErrorFailedToGetItems, err)
+		}
+		items = append(items, output.Items...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func (r *dynamoDBRepository) Count(ctx context.Context, entity EntityType, opts ...ReadOption) (int, error) {
+	o := readOptions(opts)
+	filter, names, values := entityFilter(ctx, entity)
+	if conditions := o.filterExpression(names, values); conditions != "" {
+		filter += " AND " + conditions
+	}
+	input := dynamodb.ScanInput{
+		TableName:                 aws.String(r.table.TableName),
+		Select:                    types.SelectCount,
+		FilterExpression:          aws.String(filter),
+		ConsistentRead:            aws.Bool(ConsistentRead(ctx)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	// Sum counts of all pages.
+	count := 0
+	for {
+		var output *dynamodb.ScanOutput
+		err := withRetry(ctx, "Scan", func() (err error) {
+			output, err = r.table.DynamoDbClient.Scan(ctx, &input)
+			return err
+		})
+		if err != nil {
+			logging.PrintfContext(ctx, "%v: %v", ErrorFailedToGetItems, err)
+			return 0, fmt.Errorf("%w: %w", ErrorFailedToGetItems, err)
+		}
+		count += int(output.Count)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// entityFilter returns filter expression matching items of entity type of the tenant carried
+// by ctx together with its expression attribute names and values.
+func entityFilter(ctx context.Context, entity EntityType) (string, map[string]string, Item) {
+	names := map[string]string{
+		"#entityType": EntityTypeAttribute,
+		"#tenantId":   TenantAttribute,
+	}
+	values := Item{":entityType": &types.AttributeValueMemberS{Value: string(entity)}}
+
+	t := tenant.FromContext(ctx)
+	if t == tenant.Default {
+		return "#entityType = :entityType AND attribute_not_exists(#tenantId)", names, values
+	}
+	values[":tenantId"] = &types.AttributeValueMemberS{Value: t}
+	return "#entityType = :entityType AND #tenantId = :tenantId", names, values
+}
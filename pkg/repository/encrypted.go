@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/encryption"
+)
+
+const (
+	// DataKeyAttribute holds data key of an item encrypted with master key.
+	DataKeyAttribute = "encDataKey"
+	// KeyIDAttribute holds ID of master key the data key of an item is encrypted with.
+	KeyIDAttribute = "encKeyId"
+)
+
+var (
+	ErrorFailedToEncryptItem = errors.New("failed to encrypt item")
+	ErrorFailedToDecryptItem = errors.New("failed to decrypt item")
+)
+
+// encryptedRepository encrypts configured attributes of items before they reach the
+// wrapped repository and decrypts them on read. Every item is encrypted with its own data
+// key, stored in the item encrypted with master key of the key provider together with ID
+// of the master key, so master keys can be rotated while items encrypted with previous
+// ones stay readable. Items without data key (written before encryption was enabled) are
+// returned as is.
+type encryptedRepository struct {
+	Repository
+	provider   encryption.KeyProvider
+	attributes []string
+}
+
+// NewEncrypted returns repository encrypting attributes of items stored in r with data keys
+// of provider. String and number attributes are supported. Filters on encrypted attributes
+// are evaluated after decryption, since DynamoDB can not compare ciphertexts. Ciphertexts are
+// stored as binary attributes, so encrypted attributes must not be keys of the table or of
+// its secondary indexes, declared as strings or numbers, or DynamoDB rejects writes.
+func NewEncrypted(r Repository, provider encryption.KeyProvider, attributes ...string) Repository {
+	return &encryptedRepository{Repository: r, provider: provider, attributes: attributes}
+}
+
+func (r *encryptedRepository) Get(ctx context.Context, key Key, opts ...ReadOption) (Item, error) {
+	o := readOptions(opts)
+	item, err := r.Repository.Get(ctx, key, r.storedOptions(o)...)
+	if err != nil {
+		return nil, err
+	}
+	return r.decrypt(ctx, item, o)
+}
+
+func (r *encryptedRepository) Put(ctx context.Context, item Item) error {
+	encrypted, err := r.encrypt(ctx, item)
+	if err != nil {
+		return err
+	}
+	return r.Repository.Put(ctx, encrypted)
+}
+
+func (r *encryptedRepository) Delete(ctx context.Context, key Key) (Item, error) {
+	item, err := r.Repository.Delete(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return r.decrypt(ctx, item, ReadOptions{})
+}
+
+func (r *encryptedRepository) Transact(ctx context.Context, writes ...Write) error {
+	encrypted := make([]Write, len(writes))
+	for i, w := range writes {
+		encrypted[i] = w
+		if w.Put == nil {
+			continue
+		}
+		item, err := r.encrypt(ctx, w.Put)
+		if err != nil {
+			return err
+		}
+		encrypted[i].Put = item
+	}
+	return r.Repository.Transact(ctx, encrypted...)
+}
+
+func (r *encryptedRepository) Query(ctx context.Context, pk string, skPrefix string, opts ...ReadOption) ([]Item, error) {
+	o := readOptions(opts)
+	items, err := r.Repository.Query(ctx, pk, skPrefix, r.storedOptions(o)...)
+	if err != nil {
+		return nil, err
+	}
+	return r.decryptAll(ctx, items, o)
+}
+
+func (r *encryptedRepository) Scan(ctx context.Context, entity EntityType, opts ...ReadOption) ([]Item, error) {
+	o := readOptions(opts)
+	items, err := r.Repository.Scan(ctx, entity, r.storedOptions(o)...)
+	if err != nil {
+		return nil, err
+	}
+	return r.decryptAll(ctx, items, o)
+}
+
+func (r *encryptedRepository) Count(ctx context.Context, entity EntityType, opts ...ReadOption) (int, error) {
+	o := readOptions(opts)
+	if !slices.ContainsFunc(o.Filter, r.encryptedCondition) {
+		return r.Repository.Count(ctx, entity, opts...)
+	}
+
+	// Items have to be decrypted to be filtered.
+	var projection []string
+	for _, c := range o.Filter {
+		projection = append(projection, c.Attribute)
+	}
+	items, err := r.Scan(ctx, entity, WithProjection(projection...), WithFilter(o.Filter...))
+	return len(items), err
+}
+
+// encryptedCondition reports whether condition compares an encrypted attribute.
+func (r *encryptedRepository) encryptedCondition(c Condition) bool {
+	return slices.Contains(r.attributes, c.Attribute)
+}
+
+// storedOptions returns options of reading items of o from the wrapped repository. Filters
+// on encrypted attributes are left out and the projection is extended with attributes
+// required to decrypt and filter items.
+func (r *encryptedRepository) storedOptions(o ReadOptions) []ReadOption {
+	var stored ReadOptions
+	var decrypted []string
+	for _, c := range o.Filter {
+		if r.encryptedCondition(c) {
+			decrypted = append(decrypted, c.Attribute)
+		} else {
+			stored.Filter = append(stored.Filter, c)
+		}
+	}
+
+	if len(o.Projection) > 0 {
+		stored.Projection = slices.Clone(o.Projection)
+		for _, attribute := range append(decrypted, PartitionKeyAttribute, DataKeyAttribute, KeyIDAttribute) {
+			if !slices.Contains(stored.Projection, attribute) {
+				stored.Projection = append(stored.Projection, attribute)
+			}
+		}
+	}
+	return []ReadOption{WithProjection(stored.Projection...), WithFilter(stored.Filter...)}
+}
+
+// encrypt returns copy of item with encrypted attributes replaced with their ciphertexts.
+func (r *encryptedRepository) encrypt(ctx context.Context, item Item) (Item, error) {
+	if !slices.ContainsFunc(r.attributes, func(a string) bool { _, ok := item[a]; return ok }) {
+		return item, nil
+	}
+
+	keyID, dataKey, encryptedDataKey, err := r.provider.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToEncryptItem, err)
+	}
+
+	encrypted := copyItem(item)
+	pk := stringAttribute(item, PartitionKeyAttribute)
+	for _, attribute := range r.attributes {
+		v, ok := item[attribute]
+		if !ok {
+			continue
+		}
+		plaintext, err := marshalAttribute(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %w", ErrorFailedToEncryptItem, attribute, err)
+		}
+		ciphertext, err := encryption.Seal(dataKey, plaintext, additionalData(pk, attribute))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %w", ErrorFailedToEncryptItem, attribute, err)
+		}
+		encrypted[attribute] = &types.AttributeValueMemberB{Value: ciphertext}
+	}
+	encrypted[DataKeyAttribute] = &types.AttributeValueMemberB{Value: encryptedDataKey}
+	encrypted[KeyIDAttribute] = &types.AttributeValueMemberS{Value: keyID}
+	return encrypted, nil
+}
+
+// decrypt returns item with encrypted attributes decrypted, or nil if it does not match
+// filter of o. The item is limited to the projection of o.
+func (r *encryptedRepository) decrypt(ctx context.Context, item Item, o ReadOptions) (Item, error) {
+	encryptedDataKey, ok := item[DataKeyAttribute].(*types.AttributeValueMemberB)
+	if ok {
+		dataKey, err := r.provider.DecryptDataKey(ctx, stringAttribute(item, KeyIDAttribute), encryptedDataKey.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrorFailedToDecryptItem, err)
+		}
+
+		pk := stringAttribute(item, PartitionKeyAttribute)
+		for _, attribute := range r.attributes {
+			ciphertext, ok := item[attribute].(*types.AttributeValueMemberB)
+			if !ok {
+				continue
+			}
+			plaintext, err := encryption.Open(dataKey, ciphertext.Value, additionalData(pk, attribute))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v: %w", ErrorFailedToDecryptItem, attribute, err)
+			}
+			item[attribute], err = unmarshalAttribute(plaintext)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v: %w", ErrorFailedToDecryptItem, attribute, err)
+			}
+		}
+		delete(item, DataKeyAttribute)
+		delete(item, KeyIDAttribute)
+	}
+
+	if !o.matches(item) {
+		return nil, nil
+	}
+	return o.project(item), nil
+}
+
+// decryptAll returns decrypted items matching filter of o.
+func (r *encryptedRepository) decryptAll(ctx context.Context, items []Item, o ReadOptions) ([]Item, error) {
+	var decrypted []Item
+	for _, item := range items {
+		item, err := r.decrypt(ctx, item, o)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			decrypted = append(decrypted, item)
+		}
+	}
+	return decrypted, nil
+}
+
+// additionalData binds ciphertext of attribute to the item, so it can not be moved to
+// another item or attribute.
+func additionalData(pk string, attribute string) []byte {
+	return []byte(pk + "\x00" + attribute)
+}
+
+// marshalAttribute returns plaintext of string or number attribute prefixed with its type.
+func marshalAttribute(v types.AttributeValue) ([]byte, error) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return append([]byte("S"), v.Value...), nil
+	case *types.AttributeValueMemberN:
+		return append([]byte("N"), v.Value...), nil
+	}
+	return nil, fmt.Errorf("unsupported attribute type %T", v)
+}
+
+// unmarshalAttribute returns attribute of plaintext returned by marshalAttribute.
+func unmarshalAttribute(plaintext []byte) (types.AttributeValue, error) {
+	if len(plaintext) == 0 {
+		return nil, errors.New("empty plaintext")
+	}
+	switch plaintext[0] {
+	case 'S':
+		return &types.AttributeValueMemberS{Value: string(plaintext[1:])}, nil
+	case 'N':
+		return &types.AttributeValueMemberN{Value: string(plaintext[1:])}, nil
+	}
+	return nil, fmt.Errorf("unsupported attribute type %c", plaintext[0])
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+)
+
+// memoryRepository stores items in memory, it is meant for tests and local runs.
+type memoryRepository struct {
+	mu    sync.RWMutex
+	items map[Key]Item
+}
+
+// NewMemory returns empty repository storing items in memory.
+func NewMemory() Repository {
+	return &memoryRepository{items: map[Key]Item{}}
+}
+
+func (r *memoryRepository) Get(_ context.Context, key Key, opts ...ReadOption) (Item, error) {
+	if err := key.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToGetItem, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.items[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrorItemNotFound, key)
+	}
+	return readOptions(opts).project(item), nil
+}
+
+func (r *memoryRepository) Put(_ context.Context, item Item) error {
+	key := KeyOf(item)
+	if err := key.validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrorFailedToPutItem, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items[key] = copyItem(item)
+	return nil
+}
+
+func (r *memoryRepository) Delete(_ context.Context, key Key) (Item, error) {
+	if err := key.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToDeleteItem, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrorItemNotFound, key)
+	}
+	delete(r.items, key)
+	return item, nil
+}
+
+func (r *memoryRepository) Transact(_ context.Context, writes ...Write) error {
+	for _, w := range writes {
+		if err := w.key().validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrorFailedToWriteItems, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, w := range writes {
+		if w.Put != nil {
+			r.items[w.key()] = copyItem(w.Put)
+		} else {
+			delete(r.items, w.Delete)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) Query(_ context.Context, pk string, skPrefix string, opts ...ReadOption) ([]Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o := readOptions(opts)
+	var keys []Key
+	for key, item := range r.items {
+		if key.PK == pk && strings.HasPrefix(key.SK, skPrefix) && o.matches(item) {
+			keys = append(keys, key)
+		}
+	}
+	return r.itemsOf(keys, o), nil
+}
+
+func (r *memoryRepository) Scan(ctx context.Context, entity EntityType, opts ...ReadOption) ([]Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o := readOptions(opts)
+	t := tenant.FromContext(ctx)
+	var keys []Key
+	for key, item := range r.items {
+		if stringAttribute(item, EntityTypeAttribute) == string(entity) &&
+			stringAttribute(item, TenantAttribute) == t && o.matches(item) {
+			keys = append(keys, key)
+		}
+	}
+	return r.itemsOf(keys, o), nil
+}
+
+func (r *memoryRepository) Count(ctx context.Context, entity EntityType, opts ...ReadOption) (int, error) {
+	items, err := r.Scan(ctx, entity, WithFilter(readOptions(opts).Filter...))
+	return len(items), err
+}
+
+// itemsOf returns projected copies of items with keys ordered by partition and sort key.
+func (r *memoryRepository) itemsOf(keys []Key, o ReadOptions) []Item {
+	slices.SortFunc(keys, func(a, b Key) int {
+		if c := strings.Compare(a.PK, b.PK); c != 0 {
+			return c
+		}
+		return strings.Compare(a.SK, b.SK)
+	})
+
+	var items []Item
+	for _, key := range keys {
+		items = append(items, o.project(r.items[key]))
+	}
+	return items
+}
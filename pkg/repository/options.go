@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ReadOptions configures reads of items.
+type ReadOptions struct {
+	// Projection limits attributes of returned items, all attributes are returned if empty.
+	Projection []string
+	// Filter limits returned items to items matching all conditions.
+	Filter []Condition
+}
+
+// ReadOption modifies ReadOptions.
+type ReadOption func(*ReadOptions)
+
+// WithProjection returns option limiting attributes of returned items to attributes.
+func WithProjection(attributes ...string) ReadOption {
+	return func(o *ReadOptions) {
+		o.Projection = append(o.Projection, attributes...)
+	}
+}
+
+// readOptions applies opts to empty ReadOptions.
+func readOptions(opts []ReadOption) ReadOptions {
+	var o ReadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// projectionExpression returns ProjectionExpression of the projection or nil if there is
+// none. Attribute names are added to names as placeholders, so reserved words are allowed.
+func (o ReadOptions) projectionExpression(names map[string]string) *string {
+	if len(o.Projection) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(o.Projection))
+	for i, attribute := range o.Projection {
+		placeholders[i] = fmt.Sprintf("#p%d", i)
+		names[placeholders[i]] = attribute
+	}
+	expression := strings.Join(placeholders, ", ")
+	return &expression
+}
+
+// project returns copy of item limited to the projection.
+func (o ReadOptions) project(item Item) Item {
+	if len(o.Projection) == 0 {
+		return copyItem(item)
+	}
+
+	projected := Item{}
+	for _, attribute := range o.Projection {
+		if v, ok := item[attribute]; ok {
+			projected[attribute] = v
+		}
+	}
+	return projected
+}
+
+// Operator is a comparison operator of a filter condition.
+type Operator string
+
+const (
+	Equal          Operator = "="
+	NotEqual       Operator = "<>"
+	Less           Operator = "<"
+	LessOrEqual    Operator = "<="
+	Greater        Operator = ">"
+	GreaterOrEqual Operator = ">="
+	BeginsWith     Operator = "begins_with"
+)
+
+// Condition compares attribute of items with value. Items without the attribute do not
+// match any condition.
+type Condition struct {
+	Attribute string
+	Operator  Operator
+	Value     types.AttributeValue
+}
+
+// WithFilter returns option limiting returned items to items matching all conditions.
+// Conditions are evaluated by DynamoDB on every page, before items are returned.
+func WithFilter(conditions ...Condition) ReadOption {
+	return func(o *ReadOptions) {
+		o.Filter = append(o.Filter, conditions...)
+	}
+}
+
+// filterExpression returns filter expression of the conditions joined with AND or empty
+// string if there are none. Attribute names and values are added to names and values as
+// placeholders, so neither of them can alter the expression.
+func (o ReadOptions) filterExpression(names map[string]string, values Item) string {
+	var terms []string
+	for i, c := range o.Filter {
+		name, value := fmt.Sprintf("#f%d", i), fmt.Sprintf(":f%d", i)
+		names[name] = c.Attribute
+		values[value] = c.Value
+		if c.Operator == BeginsWith {
+			terms = append(terms, fmt.Sprintf("begins_with(%v, %v)", name, value))
+		} else {
+			terms = append(terms, fmt.Sprintf("%v %v %v", name, c.Operator, value))
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// matches reports whether item matches all conditions of the filter.
+func (o ReadOptions) matches(item Item) bool {
+	for _, c := range o.Filter {
+		if !c.matches(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether item matches condition, following DynamoDB comparison rules
+// of string and number attributes.
+func (c Condition) matches(item Item) bool {
+	var order int
+	switch v := item[c.Attribute].(type) {
+	case *types.AttributeValueMemberS:
+		value, ok := c.Value.(*types.AttributeValueMemberS)
+		if !ok {
+			return false
+		}
+		if c.Operator == BeginsWith {
+			return strings.HasPrefix(v.Value, value.Value)
+		}
+		order = strings.Compare(v.Value, value.Value)
+	case *types.AttributeValueMemberN:
+		value, ok := c.Value.(*types.AttributeValueMemberN)
+		if !ok || c.Operator == BeginsWith {
+			return false
+		}
+		a, errA := strconv.ParseFloat(v.Value, 64)
+		b, errB := strconv.ParseFloat(value.Value, 64)
+		if errA != nil || errB != nil {
+			return false
+		}
+		order = cmp.Compare(a, b)
+	case *types.AttributeValueMemberBOOL:
+		value, ok := c.Value.(*types.AttributeValueMemberBOOL)
+		if !ok || (c.Operator != Equal && c.Operator != NotEqual) {
+			return false
+		}
+		if v.Value != value.Value {
+			order = 1
+		}
+	default:
+		return false
+	}
+
+	switch c.Operator {
+	case Equal:
+		return order == 0
+	case NotEqual:
+		return order != 0
+	case Less:
+		return order < 0
+	case LessOrEqual:
+		return order <= 0
+	case Greater:
+		return order > 0
+	case GreaterOrEqual:
+		return order >= 0
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+// Repository implements access to entities stored in a single DynamoDB table with
+// partition (PK) and sort (SK) keys prefixed with entity types.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+)
+
+const (
+	PartitionKeyAttribute = "PK"
+	SortKeyAttribute      = "SK"
+	EntityTypeAttribute   = "entityType"
+	TenantAttribute       = "tenantId"
+	LinkedPKAttribute     = "linkedPK"
+	LinkedSKAttribute     = "linkedSK"
+
+	// MetadataSortKey is the sort key of the root item of an entity (e.g. user's profile).
+	MetadataSortKey = "#METADATA"
+)
+
+var (
+	ErrorItemNotFound       = errors.New("item not found")
+	ErrorInvalidKey         = errors.New("invalid key")
+	ErrorFailedToGetItem    = errors.New("failed to get item from DynamoDB")
+	ErrorFailedToGetItems   = errors.New("failed to get items from DynamoDB")
+	ErrorFailedToPutItem    = errors.New("failed to put item to DynamoDB")
+	ErrorFailedToDeleteItem = errors.New("failed to delete item from DynamoDB")
+	ErrorFailedToWriteItems = errors.New("failed to write items to DynamoDB")
+)
+
+// EntityType is the prefix of keys of items of an entity.
+type EntityType string
+
+const (
+	EntityUser       EntityType = "USER"
+	EntityGroup      EntityType = "GROUP"
+	EntityAddress    EntityType = "ADDRESS"
+	EntityPreference EntityType = "PREF"
+	EntityMembership EntityType = "MEMBERSHIP"
+)
+
+// Item is a DynamoDB item.
+type Item = map[string]types.AttributeValue
+
+// Key is the primary key of an item.
+type Key struct {
+	PK string
+	SK string
+}
+
+// validate checks that neither key nor ID of any entity in the key is empty.
+func (k Key) validate() error {
+	if k.PK == "" || k.SK == "" || strings.HasSuffix(k.PK, "#") || strings.HasSuffix(k.SK, "#") {
+		return fmt.Errorf("%w: %v", ErrorInvalidKey, k)
+	}
+	return nil
+}
+
+// Attributes returns key in a format required by DynamoDB.
+func (k Key) Attributes() Item {
+	return Item{
+		PartitionKeyAttribute: &types.AttributeValueMemberS{Value: k.PK},
+		SortKeyAttribute:      &types.AttributeValueMemberS{Value: k.SK},
+	}
+}
+
+// Write is a single write of a transaction: put of item, or delete of item with key if
+// there is no item to put.
+type Write struct {
+	Put    Item
+	Delete Key
+}
+
+// key returns key of the item written.
+func (w Write) key() Key {
+	if w.Put != nil {
+		return KeyOf(w.Put)
+	}
+	return w.Delete
+}
+
+// Repository stores items of all entity types. All operations are confined to the tenant
+// carried by ctx - keys of non-default tenants are prefixed with tenant ID. Reads are
+// eventually consistent unless ctx is created with WithConsistentRead.
+type Repository interface {
+	// Get returns item with key or ErrorItemNotFound.
+	Get(ctx context.Context, key Key, opts ...ReadOption) (Item, error)
+	// Put creates or replaces item, the item must contain its key.
+	Put(ctx context.Context, item Item) error
+	// Delete deletes item with key and returns it or ErrorItemNotFound.
+	Delete(ctx context.Context, key Key) (Item, error)
+	// Transact puts and deletes items atomically, either all writes succeed or none does.
+	// Each write must concern a different item.
+	Transact(ctx context.Context, writes ...Write) error
+	// Query returns items of partition pk with sort key starting with skPrefix ordered by sort key.
+	Query(ctx context.Context, pk string, skPrefix string, opts ...ReadOption) ([]Item, error)
+	// Scan returns items of entity type of the tenant carried by ctx.
+	Scan(ctx context.Context, entity EntityType, opts ...ReadOption) ([]Item, error)
+	// Count returns number of items of entity type of the tenant carried by ctx without
+	// returning the items, projection of opts is ignored.
+	Count(ctx context.Context, entity EntityType, opts ...ReadOption) (int, error)
+}
+
+// PartitionKey returns partition key of the entity with ID within the tenant carried by ctx.
+func PartitionKey(ctx context.Context, entity EntityType, id string) string {
+	pk := string(entity) + "#" + id
+	if t := tenant.FromContext(ctx); t != tenant.Default {
+		pk = "TENANT#" + t + "#" + pk
+	}
+	return pk
+}
+
+// EntityKey returns key of the root item of the entity with ID.
+func EntityKey(ctx context.Context, entity EntityType, id string) Key {
+	return Key{PK: PartitionKey(ctx, entity, id), SK: MetadataSortKey}
+}
+
+// ChildKey returns key of the item of child entity stored in the partition of its parent
+// entity (e.g. user's address).
+func ChildKey(ctx context.Context, parent EntityType, parentID string, child EntityType, childID string) Key {
+	return Key{PK: PartitionKey(ctx, parent, parentID), SK: SortKeyPrefix(child) + childID}
+}
+
+// SortKeyPrefix returns prefix of sort keys of items of child entity.
+func SortKeyPrefix(child EntityType) string {
+	return string(child) + "#"
+}
+
+// NewItem returns item of entity type with key and tenant attributes set.
+func NewItem(ctx context.Context, key Key, entity EntityType) Item {
+	item := key.Attributes()
+	item[EntityTypeAttribute] = &types.AttributeValueMemberS{Value: string(entity)}
+	if t := tenant.FromContext(ctx); t != tenant.Default {
+		item[TenantAttribute] = &types.AttributeValueMemberS{Value: t}
+	}
+	return item
+}
+
+// Link stores in item key of its counterpart (e.g. the other side of a many-to-many
+// relationship), so that DeleteChildren deletes the counterpart together with item.
+func Link(item Item, counterpart Key) {
+	item[LinkedPKAttribute] = &types.AttributeValueMemberS{Value: counterpart.PK}
+	item[LinkedSKAttribute] = &types.AttributeValueMemberS{Value: counterpart.SK}
+}
+
+// DeleteChildren deletes all items of partition pk except the root item of the entity,
+// together with their linked counterparts stored in other partitions.
+func DeleteChildren(ctx context.Context, r Repository, pk string) error {
+	items, err := r.Query(ctx, pk, "")
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		key := KeyOf(item)
+		if key.SK == MetadataSortKey {
+			continue
+		}
+
+		// Counterpart might have been already deleted on its own.
+		linked := Key{PK: stringAttribute(item, LinkedPKAttribute), SK: stringAttribute(item, LinkedSKAttribute)}
+		if linked.PK != "" {
+			_, err := r.Delete(ctx, linked)
+			if err != nil && !errors.Is(err, ErrorItemNotFound) {
+				return err
+			}
+		}
+
+		_, err := r.Delete(ctx, key)
+		if err != nil && !errors.Is(err, ErrorItemNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyOf returns key of item.
+func KeyOf(item Item) Key {
+	return Key{PK: stringAttribute(item, PartitionKeyAttribute), SK: stringAttribute(item, SortKeyAttribute)}
+}
+
+// stringAttribute returns value of string attribute of item or empty string.
+func stringAttribute(item Item, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// copyItem returns a shallow copy of item, so callers can not modify the stored one.
+func copyItem(item Item) Item {
+	if item == nil {
+		return nil
+	}
+	return maps.Clone(item)
+}
@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/encryption"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// repositories returns all implementations of Repository backed by empty storage.
+func repositories() map[string]func() Repository {
+	return map[string]func() Repository{
+		"Memory": NewMemory,
+		"DynamoDB": func() Repository {
+			return NewDynamoDB(models.TableBasics{
+				DynamoDbClient: testutil.NewMemoryDynamoDB(PartitionKeyAttribute, SortKeyAttribute),
+				TableName:      "test",
+			})
+		},
+		"Encrypted": func() Repository {
+			return NewEncrypted(NewMemory(), newKeyProvider("k1"), DefaultEncryptedAttributes...)
+		},
+	}
+}
+
+// newKeyProvider returns key provider with current master key and its predecessors
+// derived from their IDs.
+func newKeyProvider(current string, previous ...string) encryption.KeyProvider {
+	keys := map[string][]byte{}
+	for _, id := range append(previous, current) {
+		keys[id] = bytes.Repeat([]byte(id[len(id)-1:]), encryption.KeySize)
+	}
+	provider, err := encryption.NewLocalKeyProvider(current, keys)
+	if err != nil {
+		panic(err)
+	}
+	return provider
+}
+
+// TestKeys tests the key functions to ensure entity and child keys are prefixed with
+// entity types, and with tenant ID for non-default tenants.
+func TestKeys(t *testing.T) {
+	tenantA := tenant.WithID(context.TODO(), "tenant-a")
+
+	tests := []struct {
+		name        string
+		actualKey   Key
+		expectedKey Key
+	}{
+		{
+			name:        "User",
+			actualKey:   EntityKey(context.TODO(), EntityUser, testutil.ValidUser1.Email),
+			expectedKey: Key{PK: "USER#" + testutil.ValidUser1.Email, SK: "#METADATA"},
+		},
+		{
+			name:        "User of tenant",
+			actualKey:   EntityKey(tenantA, EntityUser, testutil.ValidUser1.Email),
+			expectedKey: Key{PK: "TENANT#tenant-a#USER#" + testutil.ValidUser1.Email, SK: "#METADATA"},
+		},
+		{
+			name:        "Address of user",
+			actualKey:   ChildKey(context.TODO(), EntityUser, testutil.ValidUser1.Email, EntityAddress, "home"),
+			expectedKey: Key{PK: "USER#" + testutil.ValidUser1.Email, SK: "ADDRESS#home"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedKey, tt.actualKey)
+		})
+	}
+}
+
+// TestConditionMatches tests the matches method of Condition to ensure string, number and
+// boolean attributes are compared like DynamoDB does, and missing attributes never match.
+func TestConditionMatches(t *testing.T) {
+	item := Item{
+		"lastName": &types.AttributeValueMemberS{Value: "Smith"},
+		"age":      &types.AttributeValueMemberN{Value: "18"},
+		"default":  &types.AttributeValueMemberBOOL{Value: true},
+	}
+
+	tests := []struct {
+		name            string
+		condition       Condition
+		expectedMatches bool
+	}{
+		{
+			name:            "Equal string",
+			condition:       Condition{"lastName", Equal, &types.AttributeValueMemberS{Value: "Smith"}},
+			expectedMatches: true,
+		},
+		{
+			name:            "Prefix of string",
+			condition:       Condition{"lastName", BeginsWith, &types.AttributeValueMemberS{Value: "Sm"}},
+			expectedMatches: true,
+		},
+		{
+			name:            "Number compared numerically",
+			condition:       Condition{"age", GreaterOrEqual, &types.AttributeValueMemberN{Value: "9"}},
+			expectedMatches: true,
+		},
+		{
+			name:            "Number less",
+			condition:       Condition{"age", Less, &types.AttributeValueMemberN{Value: "18"}},
+			expectedMatches: false,
+		},
+		{
+			name:            "Not equal boolean",
+			condition:       Condition{"default", NotEqual, &types.AttributeValueMemberBOOL{Value: false}},
+			expectedMatches: true,
+		},
+		{
+			name:            "Mismatched types",
+			condition:       Condition{"age", Equal, &types.AttributeValueMemberS{Value: "18"}},
+			expectedMatches: false,
+		},
+		{
+			name:            "Missing attribute",
+			condition:       Condition{"firstName", NotEqual, &types.AttributeValueMemberS{Value: "Ann"}},
+			expectedMatches: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedMatches, tt.condition.matches(item))
+		})
+	}
+}
+
+// TestRepository tests all implementations of Repository to ensure they store, query,
+// scan, count and delete items consistently. It verifies that scans are confined to the entity
+// type and tenant, that projections and filters limit returned attributes and items, and
+// that missing items and invalid keys are reported.
+func TestRepository(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			r := newRepository()
+			ctx := context.TODO()
+			tenantA := tenant.WithID(ctx, "tenant-a")
+
+			userKey := EntityKey(ctx, EntityUser, testutil.ValidUser1.Email)
+			user := NewItem(ctx, userKey, EntityUser)
+			user["firstName"] = &types.AttributeValueMemberS{Value: testutil.ValidUser1.FirstName}
+			addressKey := ChildKey(ctx, EntityUser, testutil.ValidUser1.Email, EntityAddress, "home")
+			address := NewItem(ctx, addressKey, EntityAddress)
+			tenantUser := NewItem(tenantA, EntityKey(tenantA, EntityUser, testutil.ValidUser2.Email), EntityUser)
+
+			for _, item := range []Item{user, address, tenantUser} {
+				assert.NoError(t, r.Put(ctx, item))
+			}
+
+			// Get.
+			actual, err := r.Get(ctx, userKey)
+			if assert.NoError(t, err) {
+				assert.Equal(t, user, actual)
+			}
+			_, err = r.Get(ctx, EntityKey(ctx, EntityUser, testutil.InvalidUser1.Email))
+			assert.ErrorIs(t, err, ErrorItemNotFound)
+			_, err = r.Get(ctx, EntityKey(ctx, EntityUser, ""))
+			assert.ErrorIs(t, err, ErrorFailedToGetItem)
+			assert.ErrorIs(t, err, ErrorInvalidKey)
+
+			// Query.
+			items, err := r.Query(ctx, userKey.PK, "")
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{user, address}, items)
+			}
+			items, err = r.Query(ctx, userKey.PK, SortKeyPrefix(EntityAddress))
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{address}, items)
+			}
+
+			// Scan.
+			items, err = r.Scan(ctx, EntityUser)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{user}, items)
+			}
+			items, err = r.Scan(tenantA, EntityUser)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{tenantUser}, items)
+			}
+
+			// Projection.
+			projected := Item{"firstName": user["firstName"]}
+			actual, err = r.Get(ctx, userKey, WithProjection("firstName", "lastName"))
+			if assert.NoError(t, err) {
+				assert.Equal(t, projected, actual)
+			}
+			items, err = r.Query(ctx, userKey.PK, SortKeyPrefix(EntityAddress), WithProjection(EntityTypeAttribute))
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{{EntityTypeAttribute: address[EntityTypeAttribute]}}, items)
+			}
+			items, err = r.Scan(ctx, EntityUser, WithProjection("firstName"))
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{projected}, items)
+			}
+
+			// Filter.
+			items, err = r.Scan(ctx, EntityUser, WithFilter(Condition{
+				Attribute: "firstName", Operator: BeginsWith, Value: &types.AttributeValueMemberS{Value: testutil.ValidUser1.FirstName[:2]},
+			}))
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{user}, items)
+			}
+			items, err = r.Scan(ctx, EntityUser, WithFilter(Condition{
+				Attribute: "firstName", Operator: Equal, Value: &types.AttributeValueMemberS{Value: "x OR 1 = 1"},
+			}))
+			if assert.NoError(t, err) {
+				assert.Empty(t, items)
+			}
+			items, err = r.Query(ctx, userKey.PK, "", WithFilter(Condition{
+				Attribute: EntityTypeAttribute, Operator: NotEqual, Value: &types.AttributeValueMemberS{Value: string(EntityUser)},
+			}))
+			if assert.NoError(t, err) {
+				assert.Equal(t, []Item{address}, items)
+			}
+
+			// Count.
+			count, err := r.Count(ctx, EntityUser)
+			if assert.NoError(t, err) {
+				assert.Equal(t, 1, count)
+			}
+			count, err = r.Count(tenantA, EntityUser, WithFilter(Condition{
+				Attribute: "firstName", Operator: Equal, Value: &types.AttributeValueMemberS{Value: testutil.ValidUser1.FirstName},
+			}))
+			if assert.NoError(t, err) {
+				assert.Equal(t, 0, count)
+			}
+
+			// Delete.
+			actual, err = r.Delete(ctx, userKey)
+			if assert.NoError(t, err) {
+				assert.Equal(t, user, actual)
+			}
+			_, err = r.Delete(ctx, userKey)
+			assert.ErrorIs(t, err, ErrorItemNotFound)
+		})
+	}
+}
+
+// TestTransact tests the Transact method of all repositories to ensure puts and deletes are
+// applied together, and none of them is applied if any write is invalid.
+func TestTransact(t *testing.T) {
+	for name, newRepository := range repositories() {
+		t.Run(name, func(t *testing.T) {
+			r := newRepository()
+			ctx := context.TODO()
+
+			homeKey := ChildKey(ctx, EntityUser, testutil.ValidUser1.Email, EntityAddress, "home")
+			workKey := ChildKey(ctx, EntityUser, testutil.ValidUser1.Email, EntityAddress, "work")
+			home := NewItem(ctx, homeKey, EntityAddress)
+			work := NewItem(ctx, workKey, EntityAddress)
+			work["firstName"] = &types.AttributeValueMemberS{Value: testutil.ValidUser1.FirstName}
+			assert.NoError(t, r.Put(ctx, home))
+
+			// Put and delete.
+			assert.NoError(t, r.Transact(ctx, Write{Put: work}, Write{Delete: homeKey}))
+			actual, err := r.Get(ctx, workKey)
+			if assert.NoError(t, err) {
+				assert.Equal(t, work, actual)
+			}
+			_, err = r.Get(ctx, homeKey)
+			assert.ErrorIs(t, err, ErrorItemNotFound)
+
+			// Invalid second write.
+			err = r.Transact(ctx, Write{Put: home}, Write{Delete: EntityKey(ctx, EntityUser, "")})
+			assert.ErrorIs(t, err, ErrorFailedToWriteItems)
+			assert.ErrorIs(t, err, ErrorInvalidKey)
+			_, err = r.Get(ctx, homeKey)
+			assert.ErrorIs(t, err, ErrorItemNotFound)
+		})
+	}
+}
+
+// consistencySpy records ConsistentRead of reads made through the in-memory DynamoDB fake.
+type consistencySpy struct {
+	*testutil.MemoryDynamoDB
+	consistent []bool
+}
+
+func (s *consistencySpy) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	s.consistent = append(s.consistent, *params.ConsistentRead)
+	return s.MemoryDynamoDB.GetItem(ctx, params, optFns...)
+}
+
+func (s *consistencySpy) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	s.consistent = append(s.consistent, *params.ConsistentRead)
+	return s.MemoryDynamoDB.Query(ctx, params, optFns...)
+}
+
+func (s *consistencySpy) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	s.consistent = append(s.consistent, *params.ConsistentRead)
+	return s.MemoryDynamoDB.Scan(ctx, params, optFns...)
+}
+
+// TestConsistentRead tests that all reads of DynamoDB repository are strongly consistent
+// for contexts created with WithConsistentRead and eventually consistent otherwise.
+func TestConsistentRead(t *testing.T) {
+	spy := &consistencySpy{MemoryDynamoDB: testutil.NewMemoryDynamoDB(PartitionKeyAttribute, SortKeyAttribute)}
+	r := NewDynamoDB(models.TableBasics{DynamoDbClient: spy, TableName: "test"})
+	key := EntityKey(context.TODO(), EntityUser, testutil.ValidUser1.Email)
+
+	for _, consistent := range []bool{false, true} {
+		ctx := context.TODO()
+		if consistent {
+			ctx = WithConsistentRead(ctx)
+		}
+		spy.consistent = nil
+
+		_, _ = r.Get(ctx, key)
+		_, _ = r.Query(ctx, key.PK, "")
+		_, _ = r.Scan(ctx, EntityUser)
+		_, _ = r.Count(ctx, EntityUser)
+		assert.Equal(t, []bool{consistent, consistent, consistent, consistent}, spy.consistent)
+	}
+}
+
+// TestEncrypted tests the encrypted repository to ensure encrypted attributes are stored
+// as ciphertexts, items stay readable after master key rotation, items written before
+// encryption was enabled are read as is, and filters on encrypted attributes are applied.
+func TestEncrypted(t *testing.T) {
+	ctx := context.TODO()
+	stored := NewMemory()
+
+	key := EntityKey(ctx, EntityUser, testutil.ValidUser1.Email)
+	user := NewItem(ctx, key, EntityUser)
+	user["email"] = &types.AttributeValueMemberS{Value: testutil.ValidUser1.Email}
+	user["firstName"] = &types.AttributeValueMemberS{Value: testutil.ValidUser1.FirstName}
+	user["age"] = &types.AttributeValueMemberN{Value: "37"}
+	legacyKey := EntityKey(ctx, EntityUser, testutil.ValidUser2.Email)
+	legacy := NewItem(ctx, legacyKey, EntityUser)
+	legacy["firstName"] = &types.AttributeValueMemberS{Value: testutil.ValidUser2.FirstName}
+	assert.NoError(t, stored.Put(ctx, legacy))
+
+	r := NewEncrypted(stored, newKeyProvider("k1"), DefaultEncryptedAttributes...)
+	assert.NoError(t, r.Put(ctx, user))
+
+	// Stored item holds ciphertexts and encrypted data key.
+	raw, err := stored.Get(ctx, key)
+	if assert.NoError(t, err) {
+		assert.Equal(t, user["email"], raw["email"])
+		assert.IsType(t, &types.AttributeValueMemberB{}, raw["firstName"])
+		assert.IsType(t, &types.AttributeValueMemberB{}, raw["age"])
+		assert.NotContains(t, string(raw["firstName"].(*types.AttributeValueMemberB).Value), testutil.ValidUser1.FirstName)
+		assert.Equal(t, &types.AttributeValueMemberS{Value: "k1"}, raw[KeyIDAttribute])
+	}
+
+	// Rotated master key.
+	r = NewEncrypted(stored, newKeyProvider("k2", "k1"), DefaultEncryptedAttributes...)
+	actual, err := r.Get(ctx, key)
+	if assert.NoError(t, err) {
+		assert.Equal(t, user, actual)
+	}
+	actual, err = r.Get(ctx, legacyKey)
+	if assert.NoError(t, err) {
+		assert.Equal(t, legacy, actual)
+	}
+
+	// Filters and projections of encrypted attributes.
+	adult := Condition{Attribute: "age", Operator: GreaterOrEqual, Value: &types.AttributeValueMemberN{Value: "18"}}
+	items, err := r.Scan(ctx, EntityUser, WithFilter(adult), WithProjection("firstName"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Item{{"firstName": user["firstName"]}}, items)
+	}
+	count, err := r.Count(ctx, EntityUser, WithFilter(adult))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, count)
+	}
+
+	// Retired master key.
+	r = NewEncrypted(stored, newKeyProvider("k3"), DefaultEncryptedAttributes...)
+	_, err = r.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrorFailedToDecryptItem)
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+)
+
+var (
+	ErrorThrottled                     = errors.New("DynamoDB request throttled")
+	ErrorProvisionedThroughputExceeded = errors.New("DynamoDB provisioned throughput exceeded")
+	ErrorTransientFailure              = errors.New("DynamoDB transient failure")
+)
+
+// RetryPolicy configures retries of throttled and transient DynamoDB calls.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff cap of the first retry, doubled on every next retry.
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff.
+	MaxDelay time.Duration
+	// Budget caps the total time spent on a single operation including backoffs.
+	Budget time.Duration
+}
+
+// DefaultRetryPolicy fits well within the 10s timeout of the Lambda function.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   50 * time.Millisecond,
+	MaxDelay:    time.Second,
+	Budget:      3 * time.Second,
+}
+
+var (
+	retryPolicy = DefaultRetryPolicy
+	sleep       = sleepContext
+)
+
+// sleepContext waits for delay, or returns error of ctx as soon as ctx is done.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryExhaustedError is returned when a DynamoDB operation kept failing with a retryable
+// error until the retry budget was exhausted. It matches both the sentinel of its class
+// (e.g. ErrorThrottled) and the last error returned by DynamoDB.
+type RetryExhaustedError struct {
+	Operation string
+	Attempts  int
+	Class     error
+	Err       error
+	// RetryAfter is the backoff the next retry would have waited.
+	RetryAfter time.Duration
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("%v: %v after %d attempts: %v", e.Operation, e.Class, e.Attempts, e.Err)
+}
+
+func (e *RetryExhaustedError) Unwrap() []error {
+	return []error{e.Class, e.Err}
+}
+
+func init() {
+	// Load retry policy of DynamoDB calls.
+	retryPolicy = retryPolicyFromEnv()
+}
+
+// errorClass describes how a DynamoDB error should be treated by the retry loop.
+type errorClass int
+
+const (
+	errorClassPermanent errorClass = iota
+	errorClassThrottling
+	errorClassProvisionedThroughput
+	errorClassTransient
+)
+
+// sentinel returns the error reported to the caller once retries are exhausted.
+func (c errorClass) sentinel() error {
+	switch c {
+	case errorClassThrottling:
+		return ErrorThrottled
+	case errorClassProvisionedThroughput:
+		return ErrorProvisionedThroughputExceeded
+	default:
+		return ErrorTransientFailure
+	}
+}
+
+// SetRetryPolicy replaces the retry policy used for DynamoDB calls.
+func SetRetryPolicy(p RetryPolicy) {
+	retryPolicy = p
+}
+
+// RetryAfter returns the delay clients are advised to wait before retrying a request that
+// failed with err: the backoff the next retry would have waited if err exhausted the retry
+// budget, at least a second.
+func RetryAfter(err error) time.Duration {
+	var exhausted *RetryExhaustedError
+	if errors.As(err, &exhausted) && exhausted.RetryAfter > time.Second {
+		return exhausted.RetryAfter
+	}
+	return time.Second
+}
+
+// retryPolicyFromEnv builds retry policy from environment variables falling back to defaults.
+func retryPolicyFromEnv() RetryPolicy {
+	p := DefaultRetryPolicy
+	if v, err := strconv.Atoi(os.Getenv("DYNAMODB_RETRY_MAX_ATTEMPTS")); err == nil && v > 0 {
+		p.MaxAttempts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("DYNAMODB_RETRY_BASE_DELAY")); err == nil && v > 0 {
+		p.BaseDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("DYNAMODB_RETRY_MAX_DELAY")); err == nil && v > 0 {
+		p.MaxDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("DYNAMODB_RETRY_BUDGET")); err == nil && v > 0 {
+		p.Budget = v
+	}
+	return p
+}
+
+// classifyError classifies DynamoDB error as throttling, provisioned throughput, transient or
+// permanent. Network errors (timeouts, connection resets, DNS failures) are transient, as the
+// SDK retries them no more. Canceled or expired contexts are permanent, though their deadline
+// errors are network timeouts too.
+func classifyError(err error) errorClass {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return errorClassPermanent
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ProvisionedThroughputExceededException":
+			return errorClassProvisionedThroughput
+		case "ThrottlingException", "RequestLimitExceeded", "TooManyRequestsException":
+			return errorClassThrottling
+		case "InternalServerError", "ServiceUnavailable":
+			return errorClassTransient
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500 {
+		return errorClassTransient
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return errorClassTransient
+	}
+
+	return errorClassPermanent
+}
+
+// ceiling returns exponential cap of delay before the given retry (starting from 1).
+func (p RetryPolicy) ceiling(retry int) time.Duration {
+	ceiling := p.BaseDelay << (retry - 1)
+	if ceiling > p.MaxDelay || ceiling <= 0 {
+		ceiling = p.MaxDelay
+	}
+	return max(ceiling, 0)
+}
+
+// backoff returns jittered exponential delay before the given retry (starting from 1).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.ceiling(retry)
+	if ceiling <= 0 {
+		return 0
+	}
+	// Full jitter spreads retries of concurrent invocations.
+	return rand.N(ceiling + 1)
+}
+
+// withRetry calls DynamoDB operation and retries it on throttling and transient errors
+// within the retry budget. Permanent errors are returned as is, errors which exhausted
+// the budget are wrapped in RetryExhaustedError. Backoff stops as soon as ctx is done.
+func withRetry(ctx context.Context, operation string, call func() error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		class := classifyError(err)
+		if class == errorClassPermanent {
+			return err
+		}
+
+		delay := retryPolicy.backoff(attempt)
+		if attempt >= retryPolicy.MaxAttempts || time.Since(start)+delay > retryPolicy.Budget {
+			logging.PrintfContext(ctx, "%v: retry budget exhausted after %d attempts: %v", operation, attempt, err)
+			return &RetryExhaustedError{
+				Operation:  operation,
+				Attempts:   attempt,
+				Class:      class.sentinel(),
+				Err:        err,
+				RetryAfter: retryPolicy.ceiling(attempt),
+			}
+		}
+
+		logging.PrintfContext(ctx, "%v: attempt %d failed, retrying in %v: %v", operation, attempt, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			logging.PrintfContext(ctx, "%v: retry abandoned after %d attempts: %v", operation, attempt, err)
+			return fmt.Errorf("%v: %w", operation, err)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// responseError returns an SDK HTTP response error with the given status code.
+func responseError(status int) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      errors.New("response error"),
+		},
+	}
+}
+
+// stubRetry replaces retry policy and sleep function for the duration of the test.
+func stubRetry(t *testing.T, p RetryPolicy) *[]time.Duration {
+	t.Helper()
+	var delays []time.Duration
+	oldPolicy, oldSleep := retryPolicy, sleep
+	retryPolicy = p
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { retryPolicy, sleep = oldPolicy, oldSleep })
+	return &delays
+}
+
+// TestClassifyError tests the classifyError function to ensure DynamoDB errors are
+// classified as throttling, provisioned throughput, transient or permanent.
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectedClass errorClass
+	}{
+		{
+			name:          "Provisioned throughput exceeded",
+			err:           &types.ProvisionedThroughputExceededException{},
+			expectedClass: errorClassProvisionedThroughput,
+		},
+		{
+			name:          "Request limit exceeded",
+			err:           &types.RequestLimitExceeded{},
+			expectedClass: errorClassThrottling,
+		},
+		{
+			name:          "Throttling exception",
+			err:           &smithy.GenericAPIError{Code: "ThrottlingException"},
+			expectedClass: errorClassThrottling,
+		},
+		{
+			name:          "Internal server error",
+			err:           &types.InternalServerError{},
+			expectedClass: errorClassTransient,
+		},
+		{
+			name:          "HTTP 503",
+			err:           responseError(http.StatusServiceUnavailable),
+			expectedClass: errorClassTransient,
+		},
+		{
+			name:          "HTTP 400",
+			err:           responseError(http.StatusBadRequest),
+			expectedClass: errorClassPermanent,
+		},
+		{
+			name:          "Connection reset",
+			err:           &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET},
+			expectedClass: errorClassTransient,
+		},
+		{
+			name:          "DNS failure",
+			err:           fmt.Errorf("send request: %w", &net.DNSError{Err: "no such host", Name: "dynamodb"}),
+			expectedClass: errorClassTransient,
+		},
+		{
+			name:          "Unexpected EOF",
+			err:           fmt.Errorf("read response: %w", io.ErrUnexpectedEOF),
+			expectedClass: errorClassTransient,
+		},
+		{
+			name:          "Context canceled",
+			err:           fmt.Errorf("operation error DynamoDB: GetItem: %w", context.Canceled),
+			expectedClass: errorClassPermanent,
+		},
+		{
+			name:          "Context deadline exceeded",
+			err:           &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded},
+			expectedClass: errorClassPermanent,
+		},
+		{
+			name:          "Validation exception",
+			err:           &smithy.GenericAPIError{Code: "ValidationException"},
+			expectedClass: errorClassPermanent,
+		},
+		{
+			name:          "Unknown error",
+			err:           errors.New("unknown error"),
+			expectedClass: errorClassPermanent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedClass, classifyError(tt.err))
+		})
+	}
+}
+
+// TestWithRetry tests the withRetry function to ensure retryable errors are retried
+// within the retry policy, permanent errors are returned immediately and errors
+// which exhausted the budget match the sentinel of their class.
+func TestWithRetry(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    40 * time.Millisecond,
+		Budget:      time.Second,
+	}
+	permanentError := errors.New("permanent error")
+
+	tests := []struct {
+		name             string
+		errors           []error
+		expectedError    error
+		expectedAttempts int
+	}{
+		{
+			name:             "Success at first attempt",
+			errors:           []error{nil},
+			expectedError:    nil,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "Success after throttling",
+			errors:           []error{&types.RequestLimitExceeded{}, nil},
+			expectedError:    nil,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "Permanent error",
+			errors:           []error{permanentError},
+			expectedError:    permanentError,
+			expectedAttempts: 1,
+		},
+		{
+			name: "Provisioned throughput exhausted",
+			errors: []error{
+				&types.ProvisionedThroughputExceededException{},
+				&types.ProvisionedThroughputExceededException{},
+				&types.ProvisionedThroughputExceededException{},
+			},
+			expectedError:    ErrorProvisionedThroughputExceeded,
+			expectedAttempts: 3,
+		},
+		{
+			name: "Transient failure exhausted",
+			errors: []error{
+				responseError(http.StatusInternalServerError),
+				&types.InternalServerError{},
+				responseError(http.StatusBadGateway),
+			},
+			expectedError:    ErrorTransientFailure,
+			expectedAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays := stubRetry(t, policy)
+
+			attempts := 0
+			err := withRetry(context.TODO(), "Test", func() error {
+				err := tt.errors[attempts]
+				attempts++
+				return err
+			})
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedAttempts, attempts)
+			assert.Len(t, *delays, tt.expectedAttempts-1)
+			for _, d := range *delays {
+				assert.LessOrEqual(t, d, policy.MaxDelay)
+			}
+		})
+	}
+}
+
+// TestWithRetryContext tests the withRetry function to ensure backoff stops as soon as the
+// context is done, rather than sleeping past the deadline of the request.
+func TestWithRetryContext(t *testing.T) {
+	stubRetry(t, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Second,
+		Budget:      time.Minute,
+	})
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	attempts := 0
+	err := withRetry(ctx, "Test", func() error {
+		attempts++
+		return &types.RequestLimitExceeded{}
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
+
+// TestSleepContext tests the sleepContext function to ensure it waits for the delay, and
+// returns error of the context once it is done before the delay.
+func TestSleepContext(t *testing.T) {
+	assert.NoError(t, sleepContext(context.TODO(), time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, sleepContext(ctx, time.Minute), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// TestWithRetryBudget tests the withRetry function to ensure it stops retrying once the
+// next backoff would exceed the retry budget, logged with ID of the request.
+func TestWithRetryBudget(t *testing.T) {
+	stubRetry(t, RetryPolicy{
+		MaxAttempts: 100,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Second,
+		Budget:      0,
+	})
+	var out bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(writer)
+
+	attempts := 0
+	err := withRetry(logging.WithRequestID(context.TODO(), "request-1"), "Test", func() error {
+		attempts++
+		return &smithy.GenericAPIError{Code: "ThrottlingException"}
+	})
+
+	var exhausted *RetryExhaustedError
+	if assert.ErrorAs(t, err, &exhausted) {
+		assert.Equal(t, 1, exhausted.Attempts)
+		assert.Equal(t, "Test", exhausted.Operation)
+		assert.ErrorIs(t, err, exhausted.Err)
+	}
+	assert.ErrorIs(t, err, ErrorThrottled)
+	assert.Equal(t, 1, attempts)
+	assert.Contains(t, out.String(), "[request-1] Test: retry budget exhausted after 1 attempts")
+}
+
+// TestRetryAfter tests the RetryAfter function to ensure clients are advised to wait the
+// backoff the next retry would have waited, at least a second.
+func TestRetryAfter(t *testing.T) {
+	stubRetry(t, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Second,
+		Budget:      time.Minute,
+	})
+
+	err := withRetry(context.TODO(), "Test", func() error {
+		return &smithy.GenericAPIError{Code: "ThrottlingException"}
+	})
+	assert.Equal(t, 4*time.Second, RetryAfter(err))
+	assert.Equal(t, time.Second, RetryAfter(&RetryExhaustedError{RetryAfter: 50 * time.Millisecond}))
+	assert.Equal(t, time.Second, RetryAfter(errors.New("unknown error")))
+}
+
+// TestRetryPolicyFromEnv tests the retryPolicyFromEnv function to ensure the retry
+// policy is read from environment variables and invalid values fall back to defaults.
+func TestRetryPolicyFromEnv(t *testing.T) {
+	t.Setenv("DYNAMODB_RETRY_MAX_ATTEMPTS", "7")
+	t.Setenv("DYNAMODB_RETRY_BASE_DELAY", "invalid")
+	t.Setenv("DYNAMODB_RETRY_MAX_DELAY", "2s")
+	t.Setenv("DYNAMODB_RETRY_BUDGET", "5s")
+
+	expected := DefaultRetryPolicy
+	expected.MaxAttempts = 7
+	expected.MaxDelay = 2 * time.Second
+	expected.Budget = 5 * time.Second
+
+	assert.Equal(t, expected, retryPolicyFromEnv())
+}
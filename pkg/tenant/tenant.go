@@ -0,0 +1,41 @@
+// Tenant implements tenant identification of requests in a multi-tenant deployment.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+var (
+	ErrorInvalidTenantID = errors.New("invalid tenant ID")
+	ErrorTenantMismatch  = errors.New("tenant ID does not match authorizer claim")
+	ErrorUntrustedTenant = errors.New("tenant ID header is not trusted without authorizer claim")
+
+	tenantIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// Default is the tenant of requests without tenant ID. Its keys have no tenant prefix (e.g.
+// USER#<email>), so deployments used by a single team need no tenant IDs.
+const Default = ""
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx carrying the tenant ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ID carried by ctx or Default if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Validate checks that tenant ID is safe to be used as a key prefix.
+func Validate(id string) error {
+	if !tenantIDRegex.MatchString(id) {
+		return ErrorInvalidTenantID
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package testutil
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	attributeNotExistsRegex = regexp.MustCompile(`^attribute_not_exists\(([#\w]+)\)$`)
+	beginsWithRegex         = regexp.MustCompile(`^begins_with\(([#\w]+), (:\w+)\)$`)
+	comparisonRegex         = regexp.MustCompile(`^([#\w]+) (=|<>|<=|>=|<|>) (:\w+)$`)
+)
+
+// MemoryDynamoDB is an in-memory fake of DynamoDB client operating on a single table
+// with string hash and range keys. Condition, filter and key condition expressions are
+// limited to attribute_not_exists(name), begins_with(name, :value) and comparisons of
+// name with :value joined with AND / OR (without parentheses). Projection expressions are limited to
+// top-level attributes.
+type MemoryDynamoDB struct {
+	HashKey  string
+	RangeKey string
+
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+// NewMemoryDynamoDB returns an empty in-memory fake of DynamoDB client.
+func NewMemoryDynamoDB(hashKey string, rangeKey string) *MemoryDynamoDB {
+	return &MemoryDynamoDB{
+		HashKey:  hashKey,
+		RangeKey: rangeKey,
+		items:    map[string]map[string]types.AttributeValue{},
+	}
+}
+
+// key returns value of the primary key of item.
+func (m *MemoryDynamoDB) key(item map[string]types.AttributeValue) (string, error) {
+	var parts []string
+	for _, name := range []string{m.HashKey, m.RangeKey} {
+		v, ok := item[name].(*types.AttributeValueMemberS)
+		if !ok || v.Value == "" {
+			return "", &smithy.GenericAPIError{Code: "ValidationException", Message: "missing key " + name}
+		}
+		parts = append(parts, v.Value)
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+func (m *MemoryDynamoDB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, err := m.key(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	expression := expression{params.ExpressionAttributeNames, nil}
+	return &dynamodb.GetItemOutput{Item: expression.project(params.ProjectionExpression, m.items[k])}, nil
+}
+
+func (m *MemoryDynamoDB) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, err := m.key(params.Item)
+	if err != nil {
+		return nil, err
+	}
+	old := m.items[k]
+	expression := expression{params.ExpressionAttributeNames, params.ExpressionAttributeValues}
+	if err := expression.check(params.ConditionExpression, old); err != nil {
+		return nil, err
+	}
+	m.items[k] = params.Item
+	return &dynamodb.PutItemOutput{Attributes: old}, nil
+}
+
+func (m *MemoryDynamoDB) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, err := m.key(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := m.items[k]
+	expression := expression{params.ExpressionAttributeNames, params.ExpressionAttributeValues}
+	if err := expression.check(params.ConditionExpression, old); err != nil {
+		return nil, err
+	}
+	delete(m.items, k)
+	return &dynamodb.DeleteItemOutput{Attributes: old}, nil
+}
+
+func (m *MemoryDynamoDB) TransactWriteItems(_ context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check all writes before applying any of them.
+	puts := map[string]map[string]types.AttributeValue{}
+	var deletes []string
+	for _, w := range params.TransactItems {
+		var (
+			k          string
+			err        error
+			condition  *string
+			expression expression
+		)
+		switch {
+		case w.Put != nil:
+			k, err = m.key(w.Put.Item)
+			condition = w.Put.ConditionExpression
+			expression.names, expression.values = w.Put.ExpressionAttributeNames, w.Put.ExpressionAttributeValues
+			puts[k] = w.Put.Item
+		case w.Delete != nil:
+			k, err = m.key(w.Delete.Key)
+			condition = w.Delete.ConditionExpression
+			expression.names, expression.values = w.Delete.ExpressionAttributeNames, w.Delete.ExpressionAttributeValues
+			deletes = append(deletes, k)
+		default:
+			err = &smithy.GenericAPIError{Code: "ValidationException", Message: "unsupported transaction item"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := expression.check(condition, m.items[k]); err != nil {
+			return nil, &types.TransactionCanceledException{Message: stringPtr("transaction cancelled: " + err.Error())}
+		}
+	}
+
+	for k, item := range puts {
+		m.items[k] = item
+	}
+	for _, k := range deletes {
+		delete(m.items, k)
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (m *MemoryDynamoDB) Query(_ context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expression := expression{params.ExpressionAttributeNames, params.ExpressionAttributeValues}
+	items, err := m.filter(expression, params.KeyConditionExpression, params.FilterExpression)
+	if err != nil {
+		return nil, err
+	}
+	items = expression.projectAll(params.ProjectionExpression, items)
+	return &dynamodb.QueryOutput{Items: items, Count: int32(len(items))}, nil
+}
+
+func (m *MemoryDynamoDB) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expression := expression{params.ExpressionAttributeNames, params.ExpressionAttributeValues}
+	items, err := m.filter(expression, nil, params.FilterExpression)
+	if err != nil {
+		return nil, err
+	}
+	items = expression.projectAll(params.ProjectionExpression, items)
+	if params.Select == types.SelectCount {
+		return &dynamodb.ScanOutput{Count: int32(len(items))}, nil
+	}
+	return &dynamodb.ScanOutput{Items: items, Count: int32(len(items))}, nil
+}
+
+// filter returns items matching all given expressions ordered by primary key.
+func (m *MemoryDynamoDB) filter(e expression, conditions ...*string) ([]map[string]types.AttributeValue, error) {
+	keys := make([]string, 0, len(m.items))
+	for k := range m.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var items []map[string]types.AttributeValue
+	for _, k := range keys {
+		matches := true
+		for _, condition := range conditions {
+			ok, err := e.evaluate(condition, m.items[k])
+			if err != nil {
+				return nil, err
+			}
+			matches = matches && ok
+		}
+		if matches {
+			items = append(items, m.items[k])
+		}
+	}
+	return items, nil
+}
+
+// expression holds expression attribute names and values of a request.
+type expression struct {
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+// check returns ConditionalCheckFailedException if condition does not hold for item.
+func (e expression) check(condition *string, item map[string]types.AttributeValue) error {
+	if item == nil {
+		item = map[string]types.AttributeValue{}
+	}
+	ok, err := e.evaluate(condition, item)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &types.ConditionalCheckFailedException{Message: stringPtr("the conditional request failed")}
+	}
+	return nil
+}
+
+// evaluate evaluates condition or filter expression against item.
+func (e expression) evaluate(condition *string, item map[string]types.AttributeValue) (bool, error) {
+	if condition == nil || *condition == "" {
+		return true, nil
+	}
+	for _, disjunct := range strings.Split(*condition, " OR ") {
+		matches := true
+		for _, term := range strings.Split(disjunct, " AND ") {
+			ok, err := e.evaluateTerm(strings.TrimSpace(term), item)
+			if err != nil {
+				return false, err
+			}
+			matches = matches && ok
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// evaluateTerm evaluates a single comparison of an expression against item.
+func (e expression) evaluateTerm(term string, item map[string]types.AttributeValue) (bool, error) {
+	if m := attributeNotExistsRegex.FindStringSubmatch(term); m != nil {
+		_, exists := item[e.name(m[1])]
+		return !exists, nil
+	}
+	if m := beginsWithRegex.FindStringSubmatch(term); m != nil {
+		prefix, ok := e.values[m[2]].(*types.AttributeValueMemberS)
+		if !ok {
+			return false, fmt.Errorf("missing expression attribute value %v", m[2])
+		}
+		v, ok := item[e.name(m[1])].(*types.AttributeValueMemberS)
+		return ok && strings.HasPrefix(v.Value, prefix.Value), nil
+	}
+	if m := comparisonRegex.FindStringSubmatch(term); m != nil {
+		value, ok := e.values[m[3]]
+		if !ok {
+			return false, fmt.Errorf("missing expression attribute value %v", m[3])
+		}
+		return compare(item[e.name(m[1])], m[2], value), nil
+	}
+	return false, fmt.Errorf("unsupported expression: %v", term)
+}
+
+// compare compares attribute with value using operator. Strings are compared
+// lexicographically, numbers numerically, other types only for equality.
+func compare(attribute types.AttributeValue, operator string, value types.AttributeValue) bool {
+	if attribute == nil {
+		return false
+	}
+
+	order := 0
+	switch a := attribute.(type) {
+	case *types.AttributeValueMemberS:
+		v, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return false
+		}
+		order = strings.Compare(a.Value, v.Value)
+	case *types.AttributeValueMemberN:
+		v, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			return false
+		}
+		x, errX := strconv.ParseFloat(a.Value, 64)
+		y, errY := strconv.ParseFloat(v.Value, 64)
+		if errX != nil || errY != nil {
+			return false
+		}
+		order = cmp.Compare(x, y)
+	default:
+		if operator != "=" && operator != "<>" {
+			return false
+		}
+		if !reflect.DeepEqual(attribute, value) {
+			order = 1
+		}
+	}
+
+	switch operator {
+	case "=":
+		return order == 0
+	case "<>":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// project returns item limited to attributes of projection expression.
+func (e expression) project(projection *string, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if projection == nil || *projection == "" || item == nil {
+		return item
+	}
+	projected := map[string]types.AttributeValue{}
+	for _, name := range strings.Split(*projection, ",") {
+		name = e.name(strings.TrimSpace(name))
+		if v, ok := item[name]; ok {
+			projected[name] = v
+		}
+	}
+	return projected
+}
+
+// projectAll returns items limited to attributes of projection expression.
+func (e expression) projectAll(projection *string, items []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+	for i, item := range items {
+		items[i] = e.project(projection, item)
+	}
+	return items
+}
+
+// name resolves expression attribute name placeholder.
+func (e expression) name(name string) string {
+	if strings.HasPrefix(name, "#") {
+		return e.names[name]
+	}
+	return name
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -52,6 +52,51 @@ var (
 	ValidUsers string = fmt.Sprintf(`[{"email":"%v","firstName":"%v","lastName":"%v","age":%v},{"email":"%v","firstName":"%v","lastName":"%v","age":%v}]`,
 		ValidUser2.Email, ValidUser2.FirstName, ValidUser2.LastName, ValidUser2.Age, ValidUser1.Email, ValidUser1.FirstName, ValidUser1.LastName, ValidUser1.Age)
 
+	ValidGroup1 = models.Group{
+		ID:          "engineering",
+		Name:        "Engineering",
+		Description: "Engineering department",
+	}
+
+	ValidGroup2 = models.Group{
+		ID:          "sales",
+		Name:        "Sales",
+		Description: "Sales department",
+	}
+
+	InvalidGroup1 = models.Group{
+		ID:   "test",
+		Name: "test",
+	}
+
+	ValidGroup string = fmt.Sprintf(`{"id":"%v","name":"%v","description":"%v"}`,
+		ValidGroup1.ID, ValidGroup1.Name, ValidGroup1.Description)
+
+	ValidAddress1 = models.Address{
+		ID:         "home",
+		Type:       "home",
+		Default:    true,
+		Line1:      "1 Main Street",
+		City:       "Springfield",
+		Region:     "IL",
+		PostalCode: "62701",
+		Country:    "US",
+	}
+
+	ValidAddress2 = models.Address{
+		ID:         "work",
+		Type:       "work",
+		Line1:      "ul. Prosta 1",
+		City:       "Warszawa",
+		PostalCode: "00-001",
+		Country:    "PL",
+	}
+
+	ValidAddress string = fmt.Sprintf(
+		`{"id":"%v","type":"%v","default":%v,"line1":"%v","city":"%v","region":"%v","postalCode":"%v","country":"%v"}`,
+		ValidAddress1.ID, ValidAddress1.Type, ValidAddress1.Default, ValidAddress1.Line1, ValidAddress1.City,
+		ValidAddress1.Region, ValidAddress1.PostalCode, ValidAddress1.Country)
+
 	ValidQueQueryStringParameters   = map[string]string{"email": ValidUser1.Email}
 	InvalidQueQueryStringParameters = map[string]string{"email": InvalidUser1.Email}
 )
@@ -0,0 +1,148 @@
+package user
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/cache"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/metrics"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+)
+
+const (
+	// CacheHitsMetric is the metric counting lookups of users served by the cache.
+	CacheHitsMetric = "UserCacheHits"
+	// CacheMissesMetric is the metric counting lookups of users missing in the cache.
+	CacheMissesMetric = "UserCacheMisses"
+	// CacheEvictionsMetric is the metric counting users evicted from the full cache.
+	CacheEvictionsMetric = "UserCacheEvictions"
+)
+
+// CachePolicy configures the cache of users in front of FetchUser.
+type CachePolicy struct {
+	// Size is the maximum number of cached users, zero disables the cache.
+	Size int
+	// TTL is the time users are cached for.
+	TTL time.Duration
+	// NegativeTTL is the time users which do not exist are cached for.
+	NegativeTTL time.Duration
+}
+
+// DefaultCachePolicy keeps the cache disabled, so deployments serving writes from many
+// containers do not read stale users unless they opt in.
+var DefaultCachePolicy = CachePolicy{
+	Size:        0,
+	TTL:         30 * time.Second,
+	NegativeTTL: 5 * time.Second,
+}
+
+var (
+	cachePolicy = DefaultCachePolicy
+	// userCache maps keys of users (including tenant prefix) to users, nil users are
+	// cached results of users which do not exist.
+	userCache *cache.LRU[string, *models.User]
+
+	// emittedMu guards emitted, the counters of the cache emitted as metrics so far.
+	emittedMu sync.Mutex
+	emitted   cache.Stats
+)
+
+func init() {
+	// Load cache policy of users.
+	SetCachePolicy(cachePolicyFromEnv())
+}
+
+// SetCachePolicy replaces the cache policy and empties the cache.
+func SetCachePolicy(p CachePolicy) {
+	cachePolicy = p
+	userCache = cache.New[string, *models.User](p.Size)
+	emittedMu.Lock()
+	emitted = cache.Stats{}
+	emittedMu.Unlock()
+}
+
+// CacheStats returns counters of lookups of the cache of users.
+func CacheStats() cache.Stats {
+	return userCache.Stats()
+}
+
+// EmitCacheMetrics emits counters of the cache of users increased since the last call as
+// metrics, so the cache can be tuned. Counters which did not change are left out.
+func EmitCacheMetrics() {
+	emittedMu.Lock()
+	defer emittedMu.Unlock()
+	stats := CacheStats()
+	for _, c := range []struct {
+		name    string
+		current int64
+		emitted int64
+	}{
+		{CacheHitsMetric, stats.Hits, emitted.Hits},
+		{CacheMissesMetric, stats.Misses, emitted.Misses},
+		{CacheEvictionsMetric, stats.Evictions, emitted.Evictions},
+	} {
+		if c.current > c.emitted {
+			metrics.Emit(c.name, float64(c.current-c.emitted), metrics.UnitCount, nil)
+		}
+	}
+	emitted = stats
+}
+
+// cachePolicyFromEnv builds cache policy from environment variables falling back to defaults.
+func cachePolicyFromEnv() CachePolicy {
+	p := DefaultCachePolicy
+	if v, err := strconv.Atoi(os.Getenv("USER_CACHE_SIZE")); err == nil && v >= 0 {
+		p.Size = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("USER_CACHE_TTL")); err == nil && v > 0 {
+		p.TTL = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("USER_CACHE_NEGATIVE_TTL")); err == nil && v > 0 {
+		p.NegativeTTL = v
+	}
+	return p
+}
+
+// cachedUser returns cached user of key. The user is nil if it is cached as not existing.
+func cachedUser(ctx context.Context, key string) (*models.User, bool) {
+	// Consistent reads must see writes of other containers.
+	if cache.Bypassed(ctx) || repository.ConsistentRead(ctx) {
+		return nil, false
+	}
+
+	u, ok := userCache.Get(key)
+	if !ok {
+		if userCache != nil {
+			logging.PrintfContext(ctx, "user cache miss: %v", key)
+		}
+		return nil, false
+	}
+	logging.PrintfContext(ctx, "user cache hit: %v", key)
+
+	// Copy user, so callers can not modify the cached one.
+	if u != nil {
+		c := *u
+		u = &c
+	}
+	return u, true
+}
+
+// cacheUser caches user of key, nil user is cached as not existing.
+func cacheUser(key string, u *models.User) {
+	if u == nil {
+		userCache.Set(key, nil, cachePolicy.NegativeTTL)
+		return
+	}
+	c := *u
+	userCache.Set(key, &c, cachePolicy.TTL)
+}
+
+// invalidateUser removes user of key from the cache.
+func invalidateUser(key string) {
+	userCache.Delete(key)
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+)
+
+var (
+	ErrorInvalidFilter = errors.New("invalid filter")
+	ErrorInvalidSort   = errors.New("invalid sort")
+
+	filterRegex = regexp.MustCompile(`^([A-Za-z]+)(\^=|!=|>=|<=|=|>|<)(.*)$`)
+
+	// filterOperators maps operators of the filter grammar to repository operators.
+	filterOperators = map[string]repository.Operator{
+		"=":  repository.Equal,
+		"!=": repository.NotEqual,
+		"<":  repository.Less,
+		"<=": repository.LessOrEqual,
+		">":  repository.Greater,
+		">=": repository.GreaterOrEqual,
+		"^=": repository.BeginsWith,
+	}
+)
+
+// ListOptions configures listing of users.
+type ListOptions struct {
+	// Fields limits fields of listed users, all fields are listed if empty.
+	Fields []string
+	// Filter limits listed users to users matching all conditions.
+	Filter []repository.Condition
+	// Sort orders listed users by the keys, in scan order if empty.
+	Sort []SortKey
+}
+
+// SortKey orders users by field.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// ParseFilter parses condition of "<field><operator><value>" form, e.g. "age>=18",
+// "lastName=Smith" or "firstName^=Ann" (prefix match). Supported operators are =, !=,
+// <, <=, >, >= and ^=. Value of age must be an integer.
+func ParseFilter(expression string) (repository.Condition, error) {
+	m := filterRegex.FindStringSubmatch(strings.TrimSpace(expression))
+	if m == nil || !slices.Contains(Fields, m[1]) {
+		return repository.Condition{}, fmt.Errorf("%w: %v", ErrorInvalidFilter, expression)
+	}
+
+	c := repository.Condition{Attribute: m[1], Operator: filterOperators[m[2]]}
+	if m[1] == "age" {
+		if _, err := strconv.Atoi(m[3]); err != nil || c.Operator == repository.BeginsWith {
+			return repository.Condition{}, fmt.Errorf("%w: %v", ErrorInvalidFilter, expression)
+		}
+		c.Value = &types.AttributeValueMemberN{Value: m[3]}
+	} else {
+		c.Value = &types.AttributeValueMemberS{Value: m[3]}
+	}
+	return c, nil
+}
+
+// ParseSort parses comma separated fields to sort by, e.g. "lastName,-age". Fields
+// prefixed with "-" are sorted in descending order.
+func ParseSort(expression string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, f := range strings.Split(expression, ",") {
+		f = strings.TrimSpace(f)
+		key := SortKey{Field: strings.TrimPrefix(f, "-"), Descending: strings.HasPrefix(f, "-")}
+		if !slices.Contains(Fields, key.Field) {
+			return nil, fmt.Errorf("%w: %v", ErrorInvalidSort, expression)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// projection returns fields to read from DynamoDB table, sort fields are read even if
+// they are not listed.
+func (o ListOptions) projection() []string {
+	if len(o.Fields) == 0 {
+		return nil
+	}
+	projection := slices.Clone(o.Fields)
+	for _, key := range o.Sort {
+		if !slices.Contains(projection, key.Field) {
+			projection = append(projection, key.Field)
+		}
+	}
+	return projection
+}
+
+// sortUsers sorts users by keys, users equal on all keys keep their order.
+func sortUsers(users []models.User, keys []SortKey) {
+	if len(keys) == 0 {
+		return
+	}
+	slices.SortStableFunc(users, func(a, b models.User) int {
+		for _, key := range keys {
+			order := compareField(a, b, key.Field)
+			if key.Descending {
+				order = -order
+			}
+			if order != 0 {
+				return order
+			}
+		}
+		return 0
+	})
+}
+
+// compareField compares field of users a and b.
+func compareField(a models.User, b models.User, field string) int {
+	switch field {
+	case "email":
+		return cmp.Compare(a.Email, b.Email)
+	case "firstName":
+		return cmp.Compare(a.FirstName, b.FirstName)
+	case "lastName":
+		return cmp.Compare(a.LastName, b.LastName)
+	case "age":
+		return cmp.Compare(a.Age, b.Age)
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseFilter tests the ParseFilter function to ensure conditions of the filter
+// grammar are parsed into repository conditions with typed values, and conditions on
+// unknown fields, with unknown operators or with values of wrong type are rejected.
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name              string
+		expression        string
+		expectedCondition repository.Condition
+		expectedError     error
+	}{
+		{
+			name:       "Number",
+			expression: "age>=18",
+			expectedCondition: repository.Condition{
+				Attribute: "age", Operator: repository.GreaterOrEqual, Value: &types.AttributeValueMemberN{Value: "18"},
+			},
+		},
+		{
+			name:       "String",
+			expression: "lastName=Smith",
+			expectedCondition: repository.Condition{
+				Attribute: "lastName", Operator: repository.Equal, Value: &types.AttributeValueMemberS{Value: "Smith"},
+			},
+		},
+		{
+			name:       "Prefix",
+			expression: "firstName^=Ann",
+			expectedCondition: repository.Condition{
+				Attribute: "firstName", Operator: repository.BeginsWith, Value: &types.AttributeValueMemberS{Value: "Ann"},
+			},
+		},
+		{
+			name:       "Value with operators",
+			expression: "lastName!=a = b OR c",
+			expectedCondition: repository.Condition{
+				Attribute: "lastName", Operator: repository.NotEqual, Value: &types.AttributeValueMemberS{Value: "a = b OR c"},
+			},
+		},
+		{
+			name:          "Unknown field",
+			expression:    "PK=USER#x",
+			expectedError: ErrorInvalidFilter,
+		},
+		{
+			name:          "Non-numeric age",
+			expression:    "age>eighteen",
+			expectedError: ErrorInvalidFilter,
+		},
+		{
+			name:          "Prefix of number",
+			expression:    "age^=1",
+			expectedError: ErrorInvalidFilter,
+		},
+		{
+			name:          "Missing operator",
+			expression:    "age",
+			expectedError: ErrorInvalidFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseFilter(tt.expression)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCondition, c)
+			}
+		})
+	}
+}
+
+// TestParseSort tests the ParseSort function to ensure ascending and descending keys are
+// parsed and unknown fields are rejected.
+func TestParseSort(t *testing.T) {
+	keys, err := ParseSort("lastName, -age")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []SortKey{{Field: "lastName"}, {Field: "age", Descending: true}}, keys)
+	}
+
+	_, err = ParseSort("lastName,-password")
+	assert.ErrorIs(t, err, ErrorInvalidSort)
+	_, err = ParseSort("")
+	assert.ErrorIs(t, err, ErrorInvalidSort)
+}
+
+// TestFetchUsersListOptions tests the FetchUsers function with filter, sort and fields to
+// ensure only matching users are listed in the requested order, and sort fields are read
+// even if they are not requested.
+func TestFetchUsersListOptions(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	ctx := context.TODO()
+	ann := models.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Smith", Age: 17}
+	for _, u := range []models.User{testutil.ValidUser1, testutil.ValidUser2, ann} {
+		assert.NoError(t, CreateUser(ctx, u))
+	}
+
+	adult, _ := ParseFilter("age>=18")
+	sort, _ := ParseSort("lastName,-age")
+
+	users, err := FetchUsers(ctx, ListOptions{Filter: []repository.Condition{adult}, Sort: sort})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.User{testutil.ValidUser1, testutil.ValidUser2}, users)
+	}
+
+	users, err = FetchUsers(ctx, ListOptions{Fields: []string{"email"}, Sort: []SortKey{{Field: "age"}}})
+	if assert.NoError(t, err) {
+		emails := []string{}
+		for _, u := range users {
+			emails = append(emails, u.Email)
+		}
+		assert.Equal(t, []string{ann.Email, testutil.ValidUser2.Email, testutil.ValidUser1.Email}, emails)
+	}
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+)
+
+var ErrorInvalidStats = errors.New("invalid stats")
+
+// DefaultAgeBucket is the width of age buckets if none is given.
+const DefaultAgeBucket = 10
+
+// StatsOptions configures statistics of users.
+type StatsOptions struct {
+	// Filter limits users to users matching all conditions, like in ListOptions.
+	Filter []repository.Condition
+	// GroupBy is the field users are counted by, "lastName" or "age" (by age bucket).
+	// Users are not grouped if empty.
+	GroupBy string
+	// AgeBucket is the width of age buckets, DefaultAgeBucket if not positive.
+	AgeBucket int
+	// Age includes minimum, maximum and mean age.
+	Age bool
+}
+
+// Stats holds statistics of users.
+type Stats struct {
+	Count  int            `json:"count"`
+	Groups map[string]int `json:"groups,omitempty"`
+	Age    *AgeStats      `json:"age,omitempty"`
+}
+
+// AgeStats holds statistics of age of users.
+type AgeStats struct {
+	Min  int     `json:"min"`
+	Max  int     `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// FetchStats computes statistics of users of the tenant carried by ctx. Bare count is
+// computed by DynamoDB without reading users, groups and age statistics read only the
+// attributes they need.
+func FetchStats(ctx context.Context, opts StatsOptions) (*Stats, error) {
+	if opts.GroupBy != "" && opts.GroupBy != "lastName" && opts.GroupBy != "age" {
+		return nil, fmt.Errorf("%w: group by %v", ErrorInvalidStats, opts.GroupBy)
+	}
+	if opts.AgeBucket <= 0 {
+		opts.AgeBucket = DefaultAgeBucket
+	}
+
+	// Count users without reading them.
+	if opts.GroupBy == "" && !opts.Age {
+		count, err := repository.Default().Count(ctx, repository.EntityUser, repository.WithFilter(opts.Filter...))
+		if err != nil {
+			return nil, err
+		}
+		return &Stats{Count: count}, nil
+	}
+
+	projection := []string{"age"}
+	if opts.GroupBy == "lastName" {
+		projection = append(projection, "lastName")
+	}
+	users, err := FetchUsers(ctx, ListOptions{Fields: projection, Filter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := Stats{Count: len(users)}
+	if opts.GroupBy != "" {
+		stats.Groups = map[string]int{}
+		for _, u := range users {
+			stats.Groups[groupOf(u, opts)]++
+		}
+	}
+	if opts.Age && len(users) > 0 {
+		stats.Age = ageStats(users)
+	}
+	logging.PrintfContext(ctx, "stats: %v", stats)
+
+	return &stats, nil
+}
+
+// groupOf returns group of user, its last name or age bucket (e.g. "30-39").
+func groupOf(u models.User, opts StatsOptions) string {
+	if opts.GroupBy == "lastName" {
+		return u.LastName
+	}
+	low := u.Age - u.Age%opts.AgeBucket
+	return fmt.Sprintf("%d-%d", low, low+opts.AgeBucket-1)
+}
+
+// ageStats returns minimum, maximum and mean age of non-empty users.
+func ageStats(users []models.User) *AgeStats {
+	s := AgeStats{Min: users[0].Age, Max: users[0].Age}
+	sum := 0
+	for _, u := range users {
+		s.Min = min(s.Min, u.Age)
+		s.Max = max(s.Max, u.Age)
+		sum += u.Age
+	}
+	s.Mean = float64(sum) / float64(len(users))
+	return &s
+}
@@ -0,0 +1,76 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFetchStats tests the FetchStats function to ensure users are counted, grouped by
+// last name or age bucket and aggregated by age, within the filter of the list endpoint.
+func TestFetchStats(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	ctx := context.TODO()
+	ann := models.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Smith", Age: 17}
+	for _, u := range []models.User{testutil.ValidUser1, testutil.ValidUser2, ann} {
+		assert.NoError(t, CreateUser(ctx, u))
+	}
+	adult, _ := ParseFilter("age>=18")
+
+	tests := []struct {
+		name          string
+		opts          StatsOptions
+		expectedStats *Stats
+		expectedError error
+	}{
+		{
+			name:          "Count",
+			opts:          StatsOptions{},
+			expectedStats: &Stats{Count: 3},
+		},
+		{
+			name:          "Filtered count",
+			opts:          StatsOptions{Filter: []repository.Condition{adult}},
+			expectedStats: &Stats{Count: 2},
+		},
+		{
+			name:          "Group by last name",
+			opts:          StatsOptions{GroupBy: "lastName"},
+			expectedStats: &Stats{Count: 3, Groups: map[string]int{"Jedrol": 2, "Smith": 1}},
+		},
+		{
+			name:          "Group by age bucket",
+			opts:          StatsOptions{GroupBy: "age", AgeBucket: 5},
+			expectedStats: &Stats{Count: 3, Groups: map[string]int{"15-19": 1, "30-34": 1, "35-39": 1}},
+		},
+		{
+			name:          "Age",
+			opts:          StatsOptions{Age: true, Filter: []repository.Condition{adult}},
+			expectedStats: &Stats{Count: 2, Age: &AgeStats{Min: 33, Max: 37, Mean: 35}},
+		},
+		{
+			name:          "Invalid group",
+			opts:          StatsOptions{GroupBy: "email"},
+			expectedError: ErrorInvalidStats,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := FetchStats(ctx, tt.opts)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedStats, stats)
+			}
+		})
+	}
+}
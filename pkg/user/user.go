@@ -4,138 +4,141 @@ package user
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"slices"
 	"strconv"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-sdk-go/aws"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/cache"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/logging"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
 	validator "github.com/go-playground/validator/v10"
 )
 
 var (
-	userTable = models.TableBasics{TableName: "de07-user"}
-	validate  *validator.Validate
-
-	ErrorFailedToLoadAWSConfig        = errors.New("failed to load AWS config")
-	ErrorFailedToCreateDynamoDBClient = errors.New("failed to create DynamoDB client")
-	ErrorFailedToUnmarshalMap         = errors.New("failed to unmarshal map for item")
-	ErrorFailedToValidateUser         = errors.New("failed to validate user")
-	ErrorUserDoesNotExist             = errors.New("user does not exist")
-	ErrorFailedToGetItem              = errors.New("failed to get item from DynamoDB")
-	ErrorFailedToGetItems             = errors.New("failed to get items from DynamoDB")
-	ErrorFailedToPutItem              = errors.New("failed to put item to DynamoDB")
-	ErrorFailedToDeleteItem           = errors.New("failed to delete item from DynamoDB")
+	validate *validator.Validate
+
+	ErrorFailedToUnmarshalMap = errors.New("failed to unmarshal map for item")
+	ErrorFailedToValidateUser = errors.New("failed to validate user")
+	ErrorUserDoesNotExist     = errors.New("user does not exist")
+	ErrorInvalidField         = errors.New("invalid field")
+	ErrorFailedToGetItem      = repository.ErrorFailedToGetItem
+	ErrorFailedToGetItems     = repository.ErrorFailedToGetItems
+	ErrorFailedToPutItem      = repository.ErrorFailedToPutItem
+	ErrorFailedToDeleteItem   = repository.ErrorFailedToDeleteItem
 )
 
-func init() {
-	// Load AWS config (~/.aws/config).
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		log.Fatalf("%v: %v", ErrorFailedToLoadAWSConfig, err)
-	}
-
-	// Create DynamoDB client.
-	userTable.DynamoDbClient = dynamodb.NewFromConfig(cfg)
-	if userTable.DynamoDbClient == nil {
-		log.Fatalf("%v: %v", ErrorFailedToCreateDynamoDBClient, err)
-	}
+// Fields are attributes of a user which can be requested with a sparse fieldset.
+var Fields = []string{"email", "firstName", "lastName", "age"}
 
+func init() {
 	// Create validator of User struct.
 	validate = validator.New()
 }
 
-// FetchUser fetches provided item from DynamoDB table based on key (email).
-func FetchUser(email string) (*models.User, error) {
-	// Build input with key (user's email).
-	input := dynamodb.GetItemInput{
-		Key: map[string]types.AttributeValue{
-			"email": &types.AttributeValueMemberS{Value: email},
-		},
-		TableName: aws.String(userTable.TableName),
+// ValidateFields checks that all fields are attributes of a user.
+func ValidateFields(fields []string) error {
+	for _, f := range fields {
+		if !slices.Contains(Fields, f) {
+			return fmt.Errorf("%w: %v", ErrorInvalidField, f)
+		}
 	}
+	return nil
+}
 
-	// Get user data from DynamoDB table.
-	r, err := userTable.DynamoDbClient.GetItem(context.TODO(), &input)
-	log.Printf("FetchUser response: %v", r)
+// FetchUser fetches provided item from DynamoDB table based on key (email) within
+// the tenant carried by ctx.
+// Users are served from the cache of the container unless ctx bypasses it. If fields are
+// given, only them are read from DynamoDB table, other fields of the user are left empty.
+func FetchUser(ctx context.Context, email string, fields ...string) (*models.User, error) {
+	err := ValidateFields(fields)
 	if err != nil {
-		log.Printf("%v: %v", ErrorFailedToGetItem, err)
-		return nil, ErrorFailedToGetItem
+		return nil, err
 	}
+	key := keyOf(ctx, email)
 
-	// Return an error if user does not exist (r.Item is nil).
-	if r.Item == nil {
-		log.Printf("%v: %v", ErrorUserDoesNotExist, err)
-		return nil, ErrorUserDoesNotExist
+	// Serve user from cache, cached users have all fields.
+	if u, ok := cachedUser(ctx, key.PK); ok {
+		if u == nil {
+			return nil, fmt.Errorf("%w: %v", ErrorUserDoesNotExist, email)
+		}
+		return u, nil
+	}
+
+	// Get user data from DynamoDB table.
+	item, err := repository.Default().Get(ctx, key, repository.WithProjection(fields...))
+	if errors.Is(err, repository.ErrorItemNotFound) {
+		logging.PrintfContext(ctx, "%v: %v", ErrorUserDoesNotExist, email)
+		cacheUser(key.PK, nil)
+		return nil, fmt.Errorf("%w: %v", ErrorUserDoesNotExist, email)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// Extract user data from DynamoDB output.
-	var u models.User
-	err = attributevalue.UnmarshalMap(r.Item, &u)
+	u, err := unmarshalUser(ctx, item)
 	if err != nil {
-		log.Printf("%v: %v, %v", ErrorFailedToUnmarshalMap, r.Item, err)
-		return nil, ErrorFailedToUnmarshalMap
+		return nil, err
+	}
+	logging.PrintfContext(ctx, "user: %v", u)
+	if len(fields) == 0 {
+		cacheUser(key.PK, u)
 	}
-	log.Printf("user: %v", u)
 
-	return &u, nil
+	return u, nil
 }
 
-// FetchUsers fetches items from DynamoDB table.
-func FetchUsers() ([]models.User, error) {
-	// Scan items of DynamoDB table.
-	input := dynamodb.ScanInput{TableName: &userTable.TableName}
-	r, err := userTable.DynamoDbClient.Scan(context.TODO(), &input)
+// FetchUsers fetches items of the tenant carried by ctx from DynamoDB table. Users are
+// filtered by DynamoDB and sorted once all pages are read. If fields are given, only them
+// (and sort fields) are read, other fields of the users are left empty.
+func FetchUsers(ctx context.Context, opts ListOptions) ([]models.User, error) {
+	err := ValidateFields(opts.Fields)
 	if err != nil {
-		log.Printf("%v: %v", ErrorFailedToGetItems, err)
-		return nil, ErrorFailedToGetItems
+		return nil, err
 	}
-	log.Printf("r.Items: %v", r.Items)
+
+	// Scan user items of DynamoDB table.
+	items, err := repository.Default().Scan(ctx, repository.EntityUser,
+		repository.WithProjection(opts.projection()...), repository.WithFilter(opts.Filter...))
+	if err != nil {
+		return nil, err
+	}
+	logging.PrintfContext(ctx, "items: %v", len(items))
 
 	// Build list of users.
 	var users []models.User
-	for _, item := range r.Items {
-		var u models.User
-		err := attributevalue.UnmarshalMap(item, &u)
+	for _, item := range items {
+		u, err := unmarshalUser(ctx, item)
 		if err != nil {
-			log.Printf("%v: %v", ErrorFailedToUnmarshalMap, err)
-			return nil, ErrorFailedToUnmarshalMap
+			return nil, err
 		}
-		users = append(users, u)
+		users = append(users, *u)
 	}
-	log.Printf("users: %v", users)
+	sortUsers(users, opts.Sort)
+	logging.PrintfContext(ctx, "users: %v", len(users))
 
 	return users, nil
 }
 
-// CreateUser creates user in DynamoDB table.
+// CreateUser creates user in DynamoDB table within the tenant carried by ctx.
 // It does not return created user - instead the user is taken from the API body request.
-func CreateUser(user models.User) error {
+func CreateUser(ctx context.Context, user models.User) error {
 	// Prepare user item with all attributes.
-	item := map[string]types.AttributeValue{
-		"email":     &types.AttributeValueMemberS{Value: user.Email},
-		"firstName": &types.AttributeValueMemberS{Value: user.FirstName},
-		"lastName":  &types.AttributeValueMemberS{Value: user.LastName},
-		"age":       &types.AttributeValueMemberN{Value: strconv.Itoa(user.Age)},
-	}
-	log.Printf("CreateUser item: %v", item)
-
-	// Prepare input for PutItem method.
-	input := dynamodb.PutItemInput{
-		Item:         item,
-		TableName:    aws.String(userTable.TableName),
-		ReturnValues: "ALL_OLD",
-	}
-	log.Printf("CreateUser input: %v", input)
-
-	// Put item into DynamoDB table.
-	_, err := userTable.DynamoDbClient.PutItem(context.TODO(), &input)
+	item := repository.NewItem(ctx, keyOf(ctx, user.Email), repository.EntityUser)
+	item["email"] = &types.AttributeValueMemberS{Value: user.Email}
+	item["firstName"] = &types.AttributeValueMemberS{Value: user.FirstName}
+	item["lastName"] = &types.AttributeValueMemberS{Value: user.LastName}
+	item["age"] = &types.AttributeValueMemberN{Value: strconv.Itoa(user.Age)}
+	logging.PrintfContext(ctx, "CreateUser key: %v", keyOf(ctx, user.Email))
+
+	// Put item into DynamoDB table, the cached user is stale even if the put failed midway.
+	err := repository.Default().Put(ctx, item)
+	invalidateUser(keyOf(ctx, user.Email).PK)
 	if err != nil {
-		log.Printf("%v: %v", ErrorFailedToPutItem, err)
-		return ErrorFailedToPutItem
+		return err
 	}
 
 	// Logging methods.
@@ -161,25 +164,26 @@ func CreateUser(user models.User) error {
 	return nil
 }
 
-// UpdateUser updates existing user in DynamoDB table.
+// UpdateUser updates existing user in DynamoDB table within the tenant carried by ctx.
 // It does not return updated user - instead the user is taken from the API body request.
-func UpdateUser(user models.User) error {
+func UpdateUser(ctx context.Context, user models.User) error {
 	// Validate user struct if it has required email field.
 	err := validate.Struct(user)
 	if err != nil {
-		log.Printf("%v: %v, %v", ErrorFailedToValidateUser, user, err)
-		return ErrorFailedToValidateUser
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToValidateUser, user, err)
+		return fmt.Errorf("%w: %w", ErrorFailedToValidateUser, err)
 	}
 
+	// Check for user existence in the storage, a cached user might be stale.
 	var u *models.User
-	u, err = FetchUser(user.Email)
+	u, err = FetchUser(cache.WithBypass(ctx), user.Email)
 	if err != nil {
 		return err // Bypassing error from the FetchUser function to the caller to build response.
 	}
 
 	// If the user exist create it again to overwrite data.
 	if u != nil {
-		err := CreateUser(user)
+		err := CreateUser(ctx, user)
 		if err != nil {
 			return err // Bypassing error from FetchUser function to the caller to build response.
 		}
@@ -188,44 +192,52 @@ func UpdateUser(user models.User) error {
 	return nil
 }
 
-// DeleteUser deletes provided item to be deleted from DynamoDB table based on key (email).1
-func DeleteUser(email string) (*models.User, error) {
-	// Check for user existence.
-	u, err := FetchUser(email)
+// DeleteUser deletes provided item to be deleted from DynamoDB table based on key (email)
+// within the tenant carried by ctx.
+func DeleteUser(ctx context.Context, email string) (*models.User, error) {
+	// Check for user existence in the storage, a cached user might be stale.
+	u, err := FetchUser(cache.WithBypass(ctx), email)
 	if err != nil {
 		return nil, err // Bypassing error from FetchUser function to the caller to build response.
 	}
 
-	// Build input with key (user's email).
-	input := dynamodb.DeleteItemInput{
-		Key: map[string]types.AttributeValue{
-			"email": &types.AttributeValueMemberS{Value: email},
-		},
-		TableName:    aws.String(userTable.TableName),
-		ReturnValues: "ALL_OLD",
+	// Delete items owned by the user (addresses and group memberships) with their linked counterparts.
+	err = repository.DeleteChildren(ctx, repository.Default(), keyOf(ctx, email).PK)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("DeleteUser input: %v", input)
 
 	// Delete item from DynamoDB table.
-	r, err := userTable.DynamoDbClient.DeleteItem(context.TODO(), &input)
-	log.Printf("DeleteItem response, err: %v: %v", r, err)
-	if err != nil {
-		log.Printf("%v: %v", ErrorFailedToDeleteItem, err)
-		return nil, ErrorFailedToDeleteItem
+	_, err = repository.Default().Delete(ctx, keyOf(ctx, email))
+	invalidateUser(keyOf(ctx, email).PK)
+	if errors.Is(err, repository.ErrorItemNotFound) {
+		logging.PrintfContext(ctx, "user does not exist: %v", email)
+		return nil, fmt.Errorf("%w: %v", ErrorUserDoesNotExist, email)
 	}
-
-	// Return an error if user does not exist (r.Attributes is nil).
-	if r.Attributes == nil {
-		log.Printf("user does not exist: %v", email)
-		return nil, ErrorUserDoesNotExist
+	if err != nil {
+		return nil, err
 	}
 
 	return u, nil
 }
 
 // GetKey returns key of a user in a required format.
-func GetKey(user models.User) map[string]types.AttributeValue {
-	return map[string]types.AttributeValue{
-		"email": &types.AttributeValueMemberS{Value: user.Email},
+func GetKey(ctx context.Context, user models.User) map[string]types.AttributeValue {
+	return keyOf(ctx, user.Email).Attributes()
+}
+
+// keyOf returns key of the profile item of a user.
+func keyOf(ctx context.Context, email string) repository.Key {
+	return repository.EntityKey(ctx, repository.EntityUser, email)
+}
+
+// unmarshalUser extracts user from DynamoDB item.
+func unmarshalUser(ctx context.Context, item repository.Item) (*models.User, error) {
+	var u models.User
+	err := attributevalue.UnmarshalMap(item, &u)
+	if err != nil {
+		logging.PrintfContext(ctx, "%v: %v, %v", ErrorFailedToUnmarshalMap, repository.KeyOf(item), err)
+		return nil, fmt.Errorf("%w: %w", ErrorFailedToUnmarshalMap, err)
 	}
+	return &u, nil
 }
@@ -1,10 +1,17 @@
 package user
 
 import (
+	"bytes"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/cache"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/metrics"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/models"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/repository"
+	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/tenant"
 	"github.com/bartlomiej-jedrol/de07-aws-serverless-api/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 )
@@ -40,12 +47,10 @@ func TestFetchUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := FetchUser(tt.email)
+			user, err := FetchUser(context.TODO(), tt.email)
 
 			if tt.expectedError != nil {
-				if assert.Error(t, err) {
-					assert.Equal(t, tt.expectedError, err)
-				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				if assert.NoError(t, err) {
 					assert.Equal(t, *tt.expectedUser, *user)
@@ -74,12 +79,10 @@ func TestFetchUsers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualUsers, err := FetchUsers()
+			actualUsers, err := FetchUsers(context.TODO(), ListOptions{})
 
 			if tt.expectedError != nil {
-				if assert.Error(t, err) {
-					assert.Equal(t, tt.expectedError, err)
-				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				if assert.NoError(t, err) {
 					assert.Equal(t, tt.expectedUsers, actualUsers)
@@ -123,13 +126,11 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := CreateUser(tt.user)
+			err := CreateUser(context.TODO(), tt.user)
 			t.Logf("err:%v", err)
 
 			if tt.expectedError != nil {
-				if assert.Error(t, err) {
-					assert.Equal(t, tt.expectedError, err)
-				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -167,13 +168,11 @@ func TestUpdateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := UpdateUser(tt.user)
+			err := UpdateUser(context.TODO(), tt.user)
 			t.Logf("err:%v", err)
 
 			if tt.expectedError != nil {
-				if assert.Error(t, err) {
-					assert.Equal(t, tt.expectedError, err)
-				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -215,13 +214,11 @@ func TestDeleteUer(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualUser, err := DeleteUser(tt.email)
+			actualUser, err := DeleteUser(context.TODO(), tt.email)
 			t.Logf("err:%v", err)
 
 			if tt.expectedError != nil {
-				if assert.Error(t, err) {
-					assert.Equal(t, tt.expectedError, err)
-				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				if assert.NoError(t, err) {
 					assert.Equal(t, tt.expectedUser, *actualUser)
@@ -238,6 +235,7 @@ func TestDeleteUer(t *testing.T) {
 func TestGetKey(t *testing.T) {
 	tests := []struct {
 		name              string
+		tenant            string
 		user              models.User
 		expectedAttribute map[string]types.AttributeValue
 	}{
@@ -245,16 +243,166 @@ func TestGetKey(t *testing.T) {
 			name: "Valid user",
 			user: testutil.ValidUser1,
 			expectedAttribute: map[string]types.AttributeValue{
-				"email": &types.AttributeValueMemberS{Value: testutil.ValidUser1.Email},
+				"PK": &types.AttributeValueMemberS{Value: "USER#" + testutil.ValidUser1.Email},
+				"SK": &types.AttributeValueMemberS{Value: "#METADATA"},
+			},
+		},
+		{
+			name:   "Valid user of tenant",
+			tenant: "tenant-a",
+			user:   testutil.ValidUser1,
+			expectedAttribute: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: "TENANT#tenant-a#USER#" + testutil.ValidUser1.Email},
+				"SK": &types.AttributeValueMemberS{Value: "#METADATA"},
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualAttribute := GetKey(tt.user)
+			actualAttribute := GetKey(tenant.WithID(context.TODO(), tt.tenant), tt.user)
 
 			assert.Equal(t, tt.expectedAttribute, actualAttribute)
 		})
 	}
 }
+
+// TestTenantIsolation tests the user functions to ensure every operation is confined
+// to the tenant carried by the context. It verifies that users of one tenant can not be
+// fetched, listed, updated or deleted by another tenant, including the default one.
+func TestTenantIsolation(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	tenantA := tenant.WithID(context.TODO(), "tenant-a")
+	tenantB := tenant.WithID(context.TODO(), "tenant-b")
+	defaultTenant := context.TODO()
+
+	assert.NoError(t, CreateUser(tenantA, testutil.ValidUser1))
+	assert.NoError(t, CreateUser(tenantB, testutil.ValidUser2))
+
+	// Reads are confined to the tenant.
+	u, err := FetchUser(tenantA, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testutil.ValidUser1, *u)
+	}
+	_, err = FetchUser(tenantB, testutil.ValidUser1.Email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+	_, err = FetchUser(defaultTenant, testutil.ValidUser1.Email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+
+	users, err := FetchUsers(tenantB, ListOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.User{testutil.ValidUser2}, users)
+	}
+	users, err = FetchUsers(defaultTenant, ListOptions{})
+	if assert.NoError(t, err) {
+		assert.Empty(t, users)
+	}
+
+	// Writes are confined to the tenant.
+	err = UpdateUser(tenantB, testutil.ValidUser1)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+	_, err = DeleteUser(tenantB, testutil.ValidUser1.Email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+
+	// The same email can be used by different tenants.
+	assert.NoError(t, CreateUser(tenantB, testutil.ValidUser1))
+	u, err = DeleteUser(tenantA, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testutil.ValidUser1, *u)
+	}
+	u, err = FetchUser(tenantB, testutil.ValidUser1.Email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testutil.ValidUser1, *u)
+	}
+}
+
+// TestFetchUserCache tests the cache in front of the FetchUser function to ensure users
+// and users which do not exist are served from the cache, writes in the same container
+// invalidate cached users, and bypassing or consistently reading contexts read through
+// to the storage.
+func TestFetchUserCache(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+	SetCachePolicy(CachePolicy{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+	defer SetCachePolicy(DefaultCachePolicy)
+
+	ctx := context.TODO()
+	email := testutil.ValidUser1.Email
+	assert.NoError(t, CreateUser(ctx, testutil.ValidUser1))
+
+	// Miss followed by hit.
+	for range 2 {
+		u, err := FetchUser(ctx, email)
+		if assert.NoError(t, err) {
+			assert.Equal(t, testutil.ValidUser1, *u)
+		}
+	}
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 1}, CacheStats())
+
+	// Counters increased since the last emission are emitted as metrics.
+	var metricsOut bytes.Buffer
+	previousOutput := metrics.SetOutput(&metricsOut)
+	defer metrics.SetOutput(previousOutput)
+	EmitCacheMetrics()
+	assert.Contains(t, metricsOut.String(), `"UserCacheHits":1`)
+	assert.Contains(t, metricsOut.String(), `"UserCacheMisses":1`)
+	assert.NotContains(t, metricsOut.String(), CacheEvictionsMetric)
+	metricsOut.Reset()
+	EmitCacheMetrics()
+	assert.Empty(t, metricsOut.String())
+
+	// Deletion by another container is not visible until bypassed.
+	_, err := repository.Default().Delete(ctx, keyOf(ctx, email))
+	assert.NoError(t, err)
+	_, err = FetchUser(ctx, email)
+	assert.NoError(t, err)
+	_, err = FetchUser(cache.WithBypass(ctx), email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+	_, err = FetchUser(repository.WithConsistentRead(ctx), email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+
+	// Users which do not exist are cached as well.
+	item := repository.NewItem(ctx, keyOf(ctx, email), repository.EntityUser)
+	item["email"] = &types.AttributeValueMemberS{Value: email}
+	assert.NoError(t, repository.Default().Put(ctx, item))
+	_, err = FetchUser(ctx, email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+
+	// Writes in the same container invalidate cached users.
+	assert.NoError(t, UpdateUser(ctx, testutil.ValidUser1))
+	u, err := FetchUser(ctx, email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testutil.ValidUser1, *u)
+	}
+	_, err = DeleteUser(ctx, email)
+	assert.NoError(t, err)
+	_, err = FetchUser(ctx, email)
+	assert.ErrorIs(t, err, ErrorUserDoesNotExist)
+}
+
+// TestFetchUserFields tests the FetchUser and FetchUsers functions with sparse fieldsets
+// to ensure only requested fields are read and unknown fields are rejected.
+func TestFetchUserFields(t *testing.T) {
+	previous := repository.SetDefault(repository.NewMemory())
+	defer repository.SetDefault(previous)
+
+	ctx := context.TODO()
+	assert.NoError(t, CreateUser(ctx, testutil.ValidUser1))
+	expected := models.User{Email: testutil.ValidUser1.Email, FirstName: testutil.ValidUser1.FirstName}
+
+	u, err := FetchUser(ctx, testutil.ValidUser1.Email, "email", "firstName")
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, *u)
+	}
+	users, err := FetchUsers(ctx, ListOptions{Fields: []string{"email", "firstName"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []models.User{expected}, users)
+	}
+
+	_, err = FetchUser(ctx, testutil.ValidUser1.Email, "email", "PK")
+	assert.ErrorIs(t, err, ErrorInvalidField)
+	_, err = FetchUsers(ctx, ListOptions{Fields: []string{"password"}})
+	assert.ErrorIs(t, err, ErrorInvalidField)
+}
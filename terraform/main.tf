@@ -45,6 +45,14 @@ resource "aws_lambda_function" "lambda_function" {
   filename      = "../build/main.zip"
 
   role = aws_iam_role.lambda_iam_role.arn
+
+  environment {
+    variables = {
+      DYNAMODB_TABLE       = aws_dynamodb_table.single_table.name
+      USER_CACHE_SIZE      = var.user_cache_size
+      CORS_ALLOWED_ORIGINS = join(",", var.cors_allowed_origins)
+    }
+  }
 }
 
 resource "aws_iam_role_policy" "lambda_inline_policy" {
@@ -60,9 +68,10 @@ resource "aws_iam_role_policy" "lambda_inline_policy" {
           "dynamodb:GetItem",
           "dynamodb:UpdateItem",
           "dynamodb:DeleteItem",
+          "dynamodb:Query",
           "dynamodb:Scan",
         ]
-        Resource = [aws_dynamodb_table.dynamodb_table.arn]
+        Resource = [aws_dynamodb_table.single_table.arn]
       },
     ],
   })
@@ -71,9 +80,14 @@ resource "aws_iam_role_policy" "lambda_inline_policy" {
 # API Gateway
 resource "aws_api_gateway_rest_api" "api_gateway" {
   name = var.api_gateway_name
+
+  # Return base64 encoded (e.g. compressed) response bodies of Lambda as binary.
+  binary_media_types = ["*/*"]
 }
 
 # DynamoDB
+# Table of users keyed by email, used before the single table. Kept until users are copied
+# to the single table with cmd/migrate (see README), then removed by hand.
 resource "aws_dynamodb_table" "dynamodb_table" {
   name         = var.dynamodb_table_name
   hash_key     = "email"
@@ -84,37 +98,26 @@ resource "aws_dynamodb_table" "dynamodb_table" {
     type = "S"
   }
 
-  attribute {
-    name = "firstName"
-    type = "S"
+  lifecycle {
+    prevent_destroy = true
   }
+}
+
+# Single table of all entities keyed by PK and SK.
+resource "aws_dynamodb_table" "single_table" {
+  name         = var.single_table_name
+  hash_key     = "PK"
+  range_key    = "SK"
+  billing_mode = "PAY_PER_REQUEST"
 
   attribute {
-    name = "lastName"
+    name = "PK"
     type = "S"
   }
 
   attribute {
-    name = "age"
-    type = "N"
-  }
-
-  global_secondary_index {
-    name            = "FirstNameIndex"
-    hash_key        = "firstName"
-    projection_type = "ALL"
-  }
-
-  global_secondary_index {
-    name            = "LastNameIndex"
-    hash_key        = "lastName"
-    projection_type = "ALL"
-  }
-
-  global_secondary_index {
-    name            = "AgeIndex"
-    hash_key        = "age"
-    projection_type = "ALL"
+    name = "SK"
+    type = "S"
   }
 }
 
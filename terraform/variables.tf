@@ -28,3 +28,18 @@ variable "dynamodb_table_name" {
   type    = string
   default = "de07-user"
 }
+
+variable "single_table_name" {
+  type    = string
+  default = "de07-api"
+}
+
+variable "user_cache_size" {
+  type    = number
+  default = 0
+}
+
+variable "cors_allowed_origins" {
+  type    = list(string)
+  default = []
+}